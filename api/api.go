@@ -1,16 +1,21 @@
 package api
 
 import (
+	"encoding/json"
 	"mime"
 	"net"
 	"net/http"
 	"path"
 
 	"github.com/euforia/metermaid"
+	"github.com/euforia/metermaid/fl"
 	"github.com/euforia/metermaid/ui"
 	"go.uber.org/zap"
 )
 
+// NextCursorHeader holds the cursor parameter for the next page of a listing
+const NextCursorHeader = "X-Next-Cursor"
+
 // API ...
 type API struct {
 	pricing   *priceAPI
@@ -22,7 +27,7 @@ type API struct {
 func New(mm metermaid.Metermaid, logger *zap.Logger) *API {
 	api := &API{
 		pricing:   &priceAPI{"/price", mm, logger},
-		container: &containerAPI{"/container", mm.Containers()},
+		container: &containerAPI{"/container", mm.Containers(), mm.ExchangeRates()},
 		log:       logger,
 	}
 
@@ -67,6 +72,33 @@ func writeErrorReponse(w http.ResponseWriter, e string) {
 	w.Write([]byte(e))
 }
 
+// writeQueryError writes a 400 with the json details of a query parse or
+// validation error
+func writeQueryError(w http.ResponseWriter, err error) {
+	b, _ := json.Marshal(fl.NewErrorResponse(err))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+	w.Write(b)
+}
+
+// writeList writes a page of the sorted items with only the requested
+// fields.  The cursor of the next page, if any, is set in the NextCursorHeader
+func writeList(w http.ResponseWriter, opts *fl.ListOptions, items []fl.Valuer) {
+	page, next := opts.Page(items)
+	out, err := opts.Project(page)
+	if err != nil {
+		writeErrorReponse(w, err.Error())
+		return
+	}
+	if next != "" {
+		w.Header().Set(NextCursorHeader, next)
+		w.Header().Set("Access-Control-Expose-Headers", NextCursorHeader)
+	}
+	b, _ := json.Marshal(out)
+	writeResponse(w, b)
+}
+
 func writeResponse(w http.ResponseWriter, b []byte) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(200)
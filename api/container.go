@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/pricing"
 	"github.com/euforia/metermaid/storage"
 	"github.com/euforia/metermaid/types"
 )
@@ -13,18 +15,29 @@ import (
 type containerAPI struct {
 	prefix string
 	store  storage.Containers
+	rates  *pricing.ExchangeRates
 }
 
 func (api *containerAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p := strings.TrimPrefix(r.URL.Path, api.prefix)
-	if p == "/" {
+	switch p {
+	case "/":
 		api.handleQuery(w, r)
 		return
+	case "/daily":
+		api.handleDaily(w, r)
+		return
+	case "/watch":
+		api.handleWatch(w, r)
+		return
 	}
 
 	switch r.Method {
 	case "GET":
 		resp, err := api.store.Get(r.URL.Path[1:])
+		if err == nil {
+			resp, err = api.convert(resp, r.URL.Query().Get("currency"))
+		}
 		switch err {
 		case nil:
 			b, _ := json.Marshal(resp)
@@ -40,15 +53,119 @@ func (api *containerAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *containerAPI) handleQuery(w http.ResponseWriter, r *http.Request) {
-	query := fl.ParseQuery(r.URL.Query())
-	out := make([]types.Container, 0)
-	api.store.Iter(func(c types.Container) error {
-		if c.Match(query) {
-			out = append(out, c)
+	params := r.URL.Query()
+	currency := params.Get("currency")
+	delete(params, "currency")
+
+	schema := types.ContainerSchema
+	opts, err := fl.ParseListOptions(params, schema)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	agg, err := fl.ParseAggregation(params, schema)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	expr, err := fl.ParseQueryExpr(params, schema)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	matched := make([]fl.Valuer, 0)
+	err = api.store.Query(expr, func(c types.Container) error {
+		c, err := api.convert(c, currency)
+		if err == nil {
+			matched = append(matched, &c)
+		}
+		return err
+	})
+	if err != nil {
+		writeErrorReponse(w, err.Error()+": "+currency)
+		return
+	}
+
+	if agg != nil {
+		b, _ := json.Marshal(agg.Apply(matched))
+		writeResponse(w, b)
+		return
+	}
+	writeList(w, &opts, matched)
+}
+
+// handleDaily returns the daily aggregates of compacted containers within
+// the optional start and end, for a single label if given
+func (api *containerAPI) handleDaily(w http.ResponseWriter, r *http.Request) {
+	compactor, ok := api.store.(storage.Compactor)
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+
+	params := r.URL.Query()
+	start, end, err := parseDateRange(params)
+	if err != nil {
+		writeErrorReponse(w, err.Error())
+		return
+	}
+	label, labelOk := params["label"]
+	currency := params.Get("currency")
+
+	out := make([]storage.DailyAggregate, 0)
+	err = compactor.Aggregates(func(agg storage.DailyAggregate) error {
+		if agg.Day.Before(start) || agg.Day.After(end) {
+			return nil
+		}
+		if labelOk && agg.Label != label[0] {
+			return nil
+		}
+		// Converted at the end of the day the containers were destroyed
+		agg.Currency, err = api.convertUnits(agg.Currency, currency, agg.Day.Add(24*time.Hour),
+			&agg.UnitsBurned, &agg.StorageUnitsBurned, &agg.NetworkUnitsBurned)
+		if err != nil {
+			return err
 		}
+		out = append(out, agg)
 		return nil
 	})
+	if err != nil {
+		writeErrorReponse(w, err.Error()+": "+currency)
+		return
+	}
 
 	b, _ := json.Marshal(out)
 	writeResponse(w, b)
 }
+
+// convert returns the container with its costs in the given currency using
+// the rate in effect when it was destroyed or now if still around
+func (api *containerAPI) convert(c types.Container, currency string) (types.Container, error) {
+	at := time.Now()
+	if c.Destroy > 0 {
+		at = time.Unix(0, c.Destroy)
+	}
+
+	var err error
+	c.Currency, err = api.convertUnits(c.Currency, currency, at,
+		&c.UnitsBurned, &c.StorageUnitsBurned, &c.NetworkUnitsBurned)
+	return c, err
+}
+
+// convertUnits converts the burned units in place to the given currency
+// using the rate in effect at the given time returning the resulting currency
+func (api *containerAPI) convertUnits(from, to string, at time.Time, units ...*float64) (string, error) {
+	if to == "" || to == from {
+		return from, nil
+	}
+
+	rate, err := api.rates.Rate(to, at)
+	if err != nil {
+		return from, err
+	}
+	for _, u := range units {
+		*u *= rate
+	}
+	return to, nil
+}
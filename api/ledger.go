@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/ledger"
+	"github.com/euforia/metermaid/types"
+)
+
+type ledgerAPI struct {
+	ledger *ledger.Ledger
+}
+
+// NewLedgerHandler returns a handler listing the replicated container
+// records held by the local node.  Records are filtered by the container
+// query and optionally the origin node name
+func NewLedgerHandler(l *ledger.Ledger) http.Handler {
+	return &ledgerAPI{ledger: l}
+}
+
+func (api *ledgerAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	origin := params.Get("origin")
+	delete(params, "origin")
+
+	expr, err := fl.ParseQueryExpr(params, types.ContainerSchema)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	out := make([]ledger.Record, 0)
+	api.ledger.Query(expr, func(rec ledger.Record) error {
+		if origin == "" || rec.Origin == origin {
+			out = append(out, rec)
+		}
+		return nil
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Origin != out[j].Origin {
+			return out[i].Origin < out[j].Origin
+		}
+		return out[i].Container.Destroy < out[j].Container.Destroy
+	})
+
+	b, _ := json.Marshal(out)
+	writeResponse(w, b)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/euforia/metermaid/fl"
+
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/storage"
+)
+
+type nodeAPI struct {
+	prefix string
+	store  storage.Nodes
+	// Metadata changes of the cluster members by name
+	histories func() map[string][]node.MetaChange
+}
+
+// NewNodeHandler returns a handler listing the cluster nodes in the store
+// and their metadata changes under the prefix
+func NewNodeHandler(prefix string, store storage.Nodes, histories func() map[string][]node.MetaChange) http.Handler {
+	return &nodeAPI{prefix: prefix, store: store, histories: histories}
+}
+
+func (api *nodeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, api.prefix)
+	switch p {
+	case "/":
+		api.handleQuery(w, r)
+	case "/history":
+		api.handleHistory(w, r)
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+// handleHistory returns the metadata changes of each member by name.  A
+// name param returns those of a single member
+func (api *nodeAPI) handleHistory(w http.ResponseWriter, r *http.Request) {
+	histories := api.histories()
+
+	var out interface{} = histories
+	if name := r.URL.Query().Get("name"); name != "" {
+		changes, ok := histories[name]
+		if !ok {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.WriteHeader(404)
+			return
+		}
+		out = changes
+	}
+
+	b, _ := json.Marshal(out)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(200)
+	w.Write(b)
+}
+
+func (api *nodeAPI) handleQuery(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	gb, ok := params["groupBy"]
+	if ok {
+		delete(params, "groupBy")
+	}
+
+	schema := node.Schema
+	opts, err := fl.ParseListOptions(params, schema)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	expr, err := fl.ParseQueryExpr(params, schema)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	// Filter
+	matched := make([]fl.Valuer, 0)
+	api.store.Iter(func(c node.Node) error {
+		if expr.Eval(&c) {
+			matched = append(matched, &c)
+		}
+		return nil
+	})
+
+	// Sort, page and project
+	page, next := opts.Page(matched)
+	items, err := opts.Project(page)
+	if err != nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(500)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// Group
+	var out interface{}
+	if ok && len(gb) > 0 {
+		grouped := make(map[string][]interface{})
+		for i, v := range page {
+			if keyV, ok := v.(*node.Node).Meta[gb[0]]; ok {
+				grouped[keyV] = append(grouped[keyV], items[i])
+			}
+		}
+		out = grouped
+	} else {
+		out = items
+	}
+
+	b, _ := json.Marshal(out)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if next != "" {
+		w.Header().Set(NextCursorHeader, next)
+		w.Header().Set("Access-Control-Expose-Headers", NextCursorHeader)
+	}
+	w.WriteHeader(200)
+	w.Write(b)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/euforia/metermaid"
+	"github.com/euforia/metermaid/fl"
 	"go.uber.org/zap"
 )
 
@@ -24,12 +25,20 @@ func (api *priceAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start, end, err := parseDateRange(r.URL.Query())
 	if err != nil {
 		writeErrorReponse(w, err.Error()+"\n"+
-			"must be RFC3339 https://tools.ietf.org/html/rfc3339\n")
+			"must be RFC3339 https://tools.ietf.org/html/rfc3339 or relative e.g. now-24h, today, startOfMonth\n")
 		return
 	}
 
 	priceHistory, err := api.mm.PriceReport(start, end)
 	if err == nil {
+		if currency := r.URL.Query().Get("currency"); currency != "" {
+			priceHistory, err = priceHistory.Convert(api.mm.ExchangeRates(), currency)
+			if err != nil {
+				writeErrorReponse(w, err.Error()+": "+currency)
+				return
+			}
+		}
+
 		var b []byte
 		if b, err = json.Marshal(priceHistory); err == nil {
 			writeResponse(w, b)
@@ -41,16 +50,20 @@ func (api *priceAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func parseDateRange(params url.Values) (start, end time.Time, err error) {
+	now := time.Now()
+
 	startStr := params["start"]
 	if len(startStr) > 0 && startStr[0] != "" {
-		start, err = time.Parse(time.RFC3339, startStr[0])
+		if start, err = fl.ParseTime(startStr[0], now); err != nil {
+			return
+		}
 	}
 
 	endStr := params["end"]
 	if len(endStr) > 0 && endStr[0] != "" {
-		end, err = time.Parse(time.RFC3339, endStr[0])
+		end, err = fl.ParseTime(endStr[0], now)
 	} else {
-		end = time.Now()
+		end = now
 	}
 	return
 }
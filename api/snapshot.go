@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/euforia/metermaid/snapshot"
+	"go.uber.org/zap"
+)
+
+// RestoreResult is the response to restoring a snapshot
+type RestoreResult struct {
+	Created    time.Time
+	Node       string
+	Containers int
+	Aggregates int
+	// Zero if prices were skipped as the snapshot is from other hardware
+	Prices int
+	Nodes  int
+}
+
+type snapshotAPI struct {
+	state snapshot.State
+	log   *zap.Logger
+}
+
+// NewSnapshotHandler returns a handler streaming a snapshot of the state on
+// GET and restoring a posted snapshot into it on POST
+func NewSnapshotHandler(state snapshot.State, logger *zap.Logger) http.Handler {
+	return &snapshotAPI{state: state, log: logger}
+}
+
+func (api *snapshotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", "attachment; filename=metermaid.snapshot")
+		w.WriteHeader(200)
+		// Headers are sent so a failure truncates the stream which fails
+		// the checksum on restore
+		if err := snapshot.Export(w, api.state); err != nil {
+			api.log.Info("snapshot export failed", zap.Error(err))
+		}
+
+	case "POST":
+		snap, err := snapshot.Read(r.Body)
+		if err != nil {
+			writeErrorReponse(w, err.Error())
+			return
+		}
+		result, err := restore(snap, api.state)
+		if err != nil {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		api.log.Info("snapshot restored",
+			zap.String("node", result.Node),
+			zap.Int("containers", result.Containers),
+			zap.Int("prices", result.Prices),
+		)
+		b, _ := json.Marshal(result)
+		writeResponse(w, b)
+
+	default:
+		w.WriteHeader(405)
+	}
+}
+
+// restore restores the snapshot into the state returning what was restored
+func restore(snap *snapshot.Snapshot, state snapshot.State) (*RestoreResult, error) {
+	prices, err := snap.Restore(state)
+	if err != nil {
+		return nil, err
+	}
+	result := &RestoreResult{
+		Created:    snap.Created,
+		Node:       snap.Node.Name,
+		Containers: len(snap.Containers),
+		Aggregates: len(snap.Aggregates),
+		Nodes:      len(snap.Nodes),
+	}
+	if prices {
+		result.Prices = len(snap.Prices)
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/storage"
+	"github.com/euforia/metermaid/types"
+)
+
+// keepAliveInterval is how often an idle event stream sends a comment so
+// proxies keep the connection open
+const keepAliveInterval = 30 * time.Second
+
+// handleWatch streams change events of the containers matching the query.
+// WebSocket upgrade requests receive a json message per event, all other
+// requests receive Server-Sent Events named by the event type.  The stream
+// ends with an overflow event if the client falls behind
+func (api *containerAPI) handleWatch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	currency := params.Get("currency")
+	delete(params, "currency")
+
+	expr, err := fl.ParseQueryExpr(params, types.ContainerSchema)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		srv := websocket.Server{
+			// Any origin is allowed as with the rest of the api
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler: func(ws *websocket.Conn) {
+				api.watchWebSocket(ws, expr, currency)
+			},
+		}
+		srv.ServeHTTP(w, r)
+		return
+	}
+
+	api.watchSSE(w, r, expr, currency)
+}
+
+func (api *containerAPI) watchSSE(w http.ResponseWriter, r *http.Request, expr fl.Expr, currency string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeErrorReponse(w, "streaming not supported")
+		return
+	}
+
+	watch := api.store.Watch(expr)
+	defer watch.Close()
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(200)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+
+		case ev, ok := <-watch.Events():
+			if !ok {
+				if watch.Overflowed() {
+					fmt.Fprintf(w, "event: %s\ndata: {}\n\n", storage.EventOverflow)
+					flusher.Flush()
+				}
+				return
+			}
+			// Without a rate the event keeps the base currency
+			if c, err := api.convert(ev.Container, currency); err == nil {
+				ev.Container = c
+			}
+			b, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b)
+		}
+		flusher.Flush()
+	}
+}
+
+func (api *containerAPI) watchWebSocket(ws *websocket.Conn, expr fl.Expr, currency string) {
+	defer ws.Close()
+
+	watch := api.store.Watch(expr)
+	defer watch.Close()
+
+	// Clients only send to close the connection
+	done := make(chan struct{})
+	go func() {
+		var msg []byte
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case ev, ok := <-watch.Events():
+			if !ok {
+				if watch.Overflowed() {
+					websocket.JSON.Send(ws, storage.Event{Type: storage.EventOverflow})
+				}
+				return
+			}
+			// Without a rate the event keeps the base currency
+			if c, err := api.convert(ev.Container, currency); err == nil {
+				ev.Container = c
+			}
+			if err := websocket.JSON.Send(ws, ev); err != nil {
+				return
+			}
+		}
+	}
+}
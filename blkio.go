@@ -0,0 +1,36 @@
+package metermaid
+
+import (
+	"time"
+
+	"github.com/euforia/metermaid/types"
+)
+
+// ioPercent returns the average block device operations per second of the
+// container relative to the provisioned IOPS of the node. It returns false
+// if the provisioned IOPS are unknown
+func (mm *meterMaid) ioPercent(c types.Container) (float64, bool) {
+	capacity := mm.Node().IOPS()
+	if capacity == 0 {
+		return 0, false
+	}
+
+	secs := time.Duration(allocEnd(c) - c.Create).Seconds()
+	if secs <= 0 {
+		return 0, true
+	}
+
+	// A container without ops still takes part in the io dimension so it
+	// is not charged more for cpu and memory than one doing io
+	ops := mm.counterIncrease(c.ID, MetricBlkReadOps) +
+		mm.counterIncrease(c.ID, MetricBlkWriteOps)
+	if ops == 0 {
+		return 0, true
+	}
+
+	pct := ops / secs / float64(capacity)
+	if pct > 1 {
+		pct = 1
+	}
+	return pct, true
+}
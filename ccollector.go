@@ -2,11 +2,13 @@ package metermaid
 
 import (
 	"context"
+	"time"
 
 	dtypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
 	"go.uber.org/zap"
 
+	"github.com/euforia/metermaid/kubelet"
 	"github.com/euforia/metermaid/types"
 )
 
@@ -14,6 +16,9 @@ import (
 type CCollector interface {
 	// Returns a channel with container state updates
 	Updates() <-chan types.Container
+	// Returns a channel with periodic resource usage samples of running
+	// containers
+	Stats() <-chan types.ContainerStats
 	Stop() error
 }
 
@@ -27,6 +32,23 @@ type CProvider interface {
 	Close() error
 }
 
+// SProvider implements a container resource usage provider
+type SProvider interface {
+	// should return a single usage sample for the container by the given id
+	Stats(ctx context.Context, id string) (*types.ContainerStats, error)
+}
+
+// LayerSizer implements a provider of container writable layer sizes.  It is
+// optional as the size is expensive to compute
+type LayerSizer interface {
+	// should return the writable layer size of the container by the given id
+	WritableLayerSize(ctx context.Context, id string) (int64, error)
+}
+
+// layerSizeInterval is the minimum interval between writable layer size
+// samples of a container
+const layerSizeInterval = 10 * time.Minute
+
 type cCollector struct {
 	// container info provider
 	cp CProvider
@@ -37,25 +59,38 @@ type cCollector struct {
 	// Outbound channel for container updates
 	out chan types.Container
 
+	// Interval between usage samples. Zero disables sampling
+	statsInterval time.Duration
+	// Container ids to sample sent to the sampler
+	sampleReqs chan []string
+	// Outbound channel for usage samples
+	stats       chan types.ContainerStats
+	samplerDone chan struct{}
+
 	cancel context.CancelFunc
 	done   chan struct{}
 
 	log *zap.Logger
 }
 
-// NewCCollector returns a new cCollector interface
-func NewCCollector(logger *zap.Logger) (CCollector, error) {
+// NewCCollector returns a new cCollector interface sampling running
+// containers at the given interval
+func NewCCollector(statsInterval time.Duration, logger *zap.Logger) (CCollector, error) {
 	dockerClient, err := NewDockerClient("")
 	if err != nil {
 		return nil, err
 	}
 
 	mm := &cCollector{
-		cp:         dockerClient,
-		containers: make(map[string]*types.Container),
-		out:        make(chan types.Container, 32),
-		done:       make(chan struct{}, 1),
-		log:        logger,
+		cp:            dockerClient,
+		containers:    make(map[string]*types.Container),
+		out:           make(chan types.Container, 32),
+		statsInterval: statsInterval,
+		sampleReqs:    make(chan []string, 1),
+		stats:         make(chan types.ContainerStats, 32),
+		samplerDone:   make(chan struct{}),
+		done:          make(chan struct{}, 1),
+		log:           logger,
 	}
 
 	if mm.log == nil {
@@ -72,6 +107,14 @@ func (mm *cCollector) run() {
 
 	mm.seedWithRunning(ctx)
 
+	go mm.runSampler(ctx)
+	var tick <-chan time.Time
+	if mm.statsInterval > 0 {
+		ticker := time.NewTicker(mm.statsInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
 	events, errs := mm.cp.(*DockerClient).Events(ctx, dtypes.EventsOptions{})
 	mm.log.Info("listening for events")
 	for {
@@ -82,9 +125,13 @@ func (mm *cCollector) run() {
 		case err := <-errs:
 			mm.log.Info("docker event error", zap.Error(err))
 
+		case <-tick:
+			mm.requestSamples()
+
 		case <-ctx.Done():
 			mm.log.Info("event loop exiting")
 			close(mm.out)
+			<-mm.samplerDone
 			close(mm.done)
 			return
 
@@ -96,6 +143,86 @@ func (mm *cCollector) Updates() <-chan types.Container {
 	return mm.out
 }
 
+func (mm *cCollector) Stats() <-chan types.ContainerStats {
+	return mm.stats
+}
+
+// requestSamples sends the ids of running containers to the sampler. The
+// request is dropped if the sampler is still busy with the previous one
+func (mm *cCollector) requestSamples() {
+	ids := make([]string, 0, len(mm.containers))
+	for id, cont := range mm.containers {
+		if cont.Start > 0 && cont.Stop == 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	select {
+	case mm.sampleReqs <- ids:
+	default:
+		mm.log.Debug("sampler busy")
+	}
+}
+
+// runSampler collects usage samples for each request. It closes the stats
+// channel on exit
+func (mm *cCollector) runSampler(ctx context.Context) {
+	defer close(mm.samplerDone)
+	defer close(mm.stats)
+
+	sp, ok := mm.cp.(SProvider)
+	if !ok {
+		<-ctx.Done()
+		return
+	}
+	ls, _ := mm.cp.(LayerSizer)
+
+	// Last writable layer size and when it was sampled by container
+	var (
+		sizes   = make(map[string]int64)
+		sizedAt = make(map[string]time.Time)
+	)
+
+	for {
+		select {
+		case ids := <-mm.sampleReqs:
+			running := make(map[string]bool, len(ids))
+			for _, id := range ids {
+				running[id] = true
+				stats, err := sp.Stats(ctx, id)
+				if err != nil {
+					mm.log.Debug("failed to sample", zap.String("id", id[:12]), zap.Error(err))
+					continue
+				}
+
+				if ls != nil && time.Since(sizedAt[id]) >= layerSizeInterval {
+					if size, err := ls.WritableLayerSize(ctx, id); err == nil {
+						sizes[id] = size
+						sizedAt[id] = time.Now()
+					}
+				}
+				stats.WritableLayerBytes = sizes[id]
+
+				select {
+				case mm.stats <- *stats:
+				case <-ctx.Done():
+					return
+				}
+			}
+			// Forget containers no longer running
+			for id := range sizedAt {
+				if !running[id] {
+					delete(sizes, id)
+					delete(sizedAt, id)
+				}
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (mm *cCollector) handleEvent(event events.Message) {
 	if event.Type != "container" {
 		return
@@ -117,6 +244,11 @@ func (mm *cCollector) handleEvent(event events.Message) {
 		var err error
 		cont, err = mm.cp.Container(context.Background(), event.Actor.ID)
 		if err == nil {
+			if kubelet.Infrastructure(cont.Labels) {
+				// Pause containers are not tracked so their later events
+				// are ignored
+				return
+			}
 			mm.containers[event.Actor.ID] = cont
 			mm.log.Debug("tracking", zap.String("id", event.Actor.ID[:12]), zap.String("action", "create"))
 		} else {
@@ -167,6 +299,9 @@ func (mm *cCollector) seedWithRunning(ctx context.Context) {
 	mm.log.Info("seeding", zap.Int("count", len(list)))
 
 	for _, cont := range list {
+		if kubelet.Infrastructure(cont.Labels) {
+			continue
+		}
 		mm.log.Info("tracking",
 			zap.String("id", cont.ID[:12]),
 			zap.String("action", "seed"),
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"sync"
+	"time"
 
 	"github.com/euforia/metermaid/tsdb"
 
+	"github.com/euforia/metermaid/ledger"
 	"github.com/euforia/metermaid/node"
 	"github.com/euforia/metermaid/types"
 	"github.com/hashicorp/memberlist"
@@ -12,13 +15,56 @@ import (
 )
 
 type GossipDelegate struct {
+	mu   sync.RWMutex
 	node node.Node
-	log  *zap.Logger
+	// Metadata changes of members by name.  These are kept after a member
+	// leaves
+	histories map[string]*node.MetaHistory
+
+	ledger *ledger.Ledger
+	log    *zap.Logger
+}
+
+// setNode replaces the local node.  Members learn of the change once the
+// pool node is updated
+func (del *GossipDelegate) setNode(n node.Node) {
+	del.mu.Lock()
+	del.node = n
+	del.mu.Unlock()
+}
+
+// recordMeta records the metadata of a member returning false if it did
+// not change
+func (del *GossipDelegate) recordMeta(n *node.Node) bool {
+	del.mu.Lock()
+	defer del.mu.Unlock()
+	if del.histories == nil {
+		del.histories = make(map[string]*node.MetaHistory)
+	}
+	h, ok := del.histories[n.Name]
+	if !ok {
+		del.histories[n.Name] = node.NewMetaHistory(n.Meta, time.Now())
+		return true
+	}
+	return h.Record(n.Meta, time.Now())
+}
+
+// MetaHistories returns the metadata changes of each member by name
+func (del *GossipDelegate) MetaHistories() map[string][]node.MetaChange {
+	del.mu.RLock()
+	defer del.mu.RUnlock()
+	out := make(map[string][]node.MetaChange, len(del.histories))
+	for name, h := range del.histories {
+		out[name] = h.Changes()
+	}
+	return out
 }
 
 // LocalState satisfies the gossip.Delegate interface
 func (del *GossipDelegate) LocalState(join bool) []byte {
+	del.mu.RLock()
 	header := []byte(del.node.Meta.String() + "\n")
+	del.mu.RUnlock()
 	if join {
 		// return del.localStateOnJoin()
 	}
@@ -55,15 +101,30 @@ func (del *GossipDelegate) MergeRemoteState(data []byte, join bool) {
 
 // NodeMeta satisfies the gossip.Delegate interface
 func (del *GossipDelegate) NodeMeta(overhead int) []byte {
+	del.mu.RLock()
+	defer del.mu.RUnlock()
 	return del.node.MarshalMeta()
 }
 
 // NotifyMsg satisfies the gossip.Delegate interface
-func (del *GossipDelegate) NotifyMsg(msg []byte) {}
+func (del *GossipDelegate) NotifyMsg(msg []byte) {
+	if del.ledger == nil {
+		return
+	}
+	// The buffer is reused once this returns and handling may send replies
+	data := make([]byte, len(msg))
+	copy(data, msg)
+	go func() {
+		if err := del.ledger.HandleMessage(data); err != nil && err != ledger.ErrNotLedgerMessage {
+			del.log.Debug("failed to handle message", zap.Error(err))
+		}
+	}()
+}
 
 // NotifyJoin satisfies the memberlist.EventDelegate interface
 func (del *GossipDelegate) NotifyJoin(nd *memberlist.Node) {
 	n := newNode(nd)
+	del.recordMeta(n)
 
 	del.log.Info("node joined",
 		zap.String("name", n.Name),
@@ -75,10 +136,14 @@ func (del *GossipDelegate) NotifyJoin(nd *memberlist.Node) {
 // NotifyLeave satisfies the memberlist.EventDelegate interface
 func (del *GossipDelegate) NotifyLeave(node *memberlist.Node) {}
 
-// NotifyUpdate satisfies the memberlist.EventDelegate interface
+// NotifyUpdate satisfies the memberlist.EventDelegate interface.  Members
+// update their node meta when their metadata or capacity is rediscovered
 func (del *GossipDelegate) NotifyUpdate(nd *memberlist.Node) {
 	n := newNode(nd)
-	del.log.Info("node updated",
+	if !del.recordMeta(n) {
+		return
+	}
+	del.log.Info("node meta changed",
 		zap.String("name", n.Name),
 		zap.String("addr", n.Address),
 		zap.String("tags", n.Meta.String()),
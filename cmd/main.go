@@ -21,8 +21,13 @@ import (
 	"github.com/euforia/gossip"
 	"github.com/euforia/metermaid"
 	"github.com/euforia/metermaid/api"
+	"github.com/euforia/metermaid/energy"
+	"github.com/euforia/metermaid/kubelet"
+	"github.com/euforia/metermaid/ledger"
 	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/nomad"
 	"github.com/euforia/metermaid/pricing"
+	"github.com/euforia/metermaid/snapshot"
 	"github.com/euforia/metermaid/storage"
 	"github.com/euforia/metermaid/types"
 )
@@ -32,6 +37,39 @@ var (
 	advAddr  = flag.String("adv-addr", "", "")
 	metaList = flag.String("meta", "", "metadata key=value, ...")
 	joinPeer = flag.String("join", "", "")
+
+	azurePrices  = flag.String("azure-prices", "", "azure retail prices export")
+	commitments  = flag.String("commitments", "", "reserved instance and savings plan json file")
+	staticPrices = flag.String("static-prices", "", "fallback instance type to hourly price json file")
+	priceCache   = flag.String("price-cache", "", "file fetched prices are kept in and served from when the provider is unreachable")
+	egressRates  = flag.String("egress-rates", "", "per GB egress rates json file")
+	egressClass  = flag.String("egress-default", "", "egress class or per GB rate of containers without the "+metermaid.EgressClassLabel+" label (default from the rates file or inter-az)")
+	statsInt     = flag.Duration("stats-interval", time.Minute, "container usage sampling interval")
+	ebsVolumes   = flag.Bool("ebs-volumes", true, "price attached ebs volumes by size and provisioned iops.  gp3 throughput above the baseline is not priced")
+	ioWeight     = flag.Float64("io-weight", 0, "weight of block io in container price (0-1)")
+	powerModel   = flag.String("power-model", "", "power model and grid intensity json file")
+	exchRates    = flag.String("exchange-rates", "", "effective dated exchange rates json file")
+	retainAge    = flag.Duration("retention-age", 0, "compact destroyed containers older than this (0 keeps all)")
+	retainCount  = flag.Int("retention-count", 0, "compact all but the most recently destroyed containers (0 keeps all)")
+	retainLabels = flag.String("retention-labels", "", "comma separated label keys compacted containers are aggregated by besides the daily totals")
+	retainValues = flag.Int("retention-label-values", 100, "values of each label aggregated per day before the rest are aggregated as "+storage.OtherValue+" (0 is unlimited)")
+	restoreFile  = flag.String("restore", "", "snapshot file to restore on startup")
+	ledgerCopies = flag.Int("ledger-replicas", 2, "peers each destroyed container record is replicated to")
+	ledgerInt    = flag.Duration("ledger-interval", time.Minute, "ledger anti-entropy interval")
+	ledgerAge    = flag.Duration("ledger-max-age", 90*24*time.Hour, "drop ledger records of containers destroyed longer ago (0 keeps all)")
+	kubeletURL   = flag.String("kubelet", "", "meter pods from the kubelet at this url instead of docker containers")
+	kubeletToken = flag.String("kubelet-token", "/var/run/secrets/kubernetes.io/serviceaccount/token", "kubelet bearer token file")
+	kubeletTLS   = flag.Bool("kubelet-insecure", false, "skip verification of the kubelet certificate")
+	kubeletPoll  = flag.Duration("kubelet-poll", 10*time.Second, "kubelet pod polling interval")
+	kubeletCPU   = flag.Float64("kubelet-min-cpu", kubelet.DefaultMinimum.CPU, "least cpus a pod is metered by e.g. BestEffort pods without requests")
+	kubeletMem   = flag.Float64("kubelet-min-memory", kubelet.DefaultMinimum.Memory/(1<<20), "least memory MiB a pod is metered by")
+	reservedCPU  = flag.Float64("reserved-cpu", 0, "cpus reserved for the os, runtime and agents")
+	reservedMem  = flag.Uint64("reserved-memory", 0, "memory MiB reserved for the os, runtime and agents")
+	measureRes   = flag.Duration("measure-reserved", 0, "measure unconfigured reservations from the system cgroup slices over this interval (0 disables)")
+	metaRefresh  = flag.Duration("meta-refresh", 5*time.Minute, "node metadata and capacity rediscovery interval (0 disables)")
+	nomadMode    = flag.Bool("nomad", false, "meter nomad allocations instead of their task containers")
+	nomadAddr    = flag.String("nomad-addr", nomad.DefaultAddr, "nomad agent address used to look up allocations (empty uses container labels only)")
+	nomadToken   = flag.String("nomad-token", os.Getenv("NOMAD_TOKEN"), "nomad acl token")
 )
 
 func init() {
@@ -45,7 +83,7 @@ func parseCLIMeta() types.Meta {
 	return types.ParseMetaFromString(*metaList)
 }
 
-func initGossip(logger *zap.Logger, node *node.Node) (*gossip.Gossip, *gossip.Pool) {
+func initGossip(logger *zap.Logger, node *node.Node) (*gossip.Gossip, *gossip.Pool, *GossipDelegate) {
 	gconf := gossip.DefaultConfig()
 
 	gconf.BindAddr, gconf.BindPort, _ = iputil.SplitHostPort(*bindAddr)
@@ -68,6 +106,8 @@ func initGossip(logger *zap.Logger, node *node.Node) (*gossip.Gossip, *gossip.Po
 	pconf.Delegate = gspDel
 	pconf.Memberlist.Events = gspDel
 	gpool := gsp.RegisterPool(pconf)
+	// Set before starting so no ledger messages are dropped
+	gspDel.ledger = ledger.New(*ledgerCopies, *ledgerAge, ledger.NewGossipTransport(gpool), logger)
 	if err = gsp.Start(); err != nil {
 		logger.Fatal("failed to start gossip", zap.Error(err))
 	}
@@ -88,7 +128,7 @@ func initGossip(logger *zap.Logger, node *node.Node) (*gossip.Gossip, *gossip.Po
 			logger.Info("failed to join peer", zap.Error(err))
 		}
 	}
-	return gsp, gpool
+	return gsp, gpool, gspDel
 }
 
 func getAddrViaSD(name string) ([]string, error) {
@@ -102,11 +142,15 @@ func getAddrViaSD(name string) ([]string, error) {
 	return out, err
 }
 
-func makeNode() *node.Node {
+// makeNode returns the node with its metadata.  On error the node holds the
+// metadata that could be discovered
+func makeNode() (*node.Node, error) {
 	nd := node.New()
-	// Explicitly for dev.  Refactor to autodetect
-	if nd.Platform.Name != "darwin" {
-		nd.Meta = node.Metadata()
+	// Metadata is nil when not on a known cloud e.g. in development
+	var err error
+	nd.Meta, err = node.Metadata()
+	if *ebsVolumes && nd.Meta[node.CloudTag] == "aws" {
+		nd.Volumes = node.AttachedVolumes()
 	}
 
 	tags := parseCLIMeta()
@@ -118,19 +162,138 @@ func makeNode() *node.Node {
 		nd.Meta = tags
 	}
 
-	return nd
+	return nd, err
+}
+
+// fleetMeta returns the metadata of the nodes in the cluster used to spread
+// reservations across the nodes they match
+func fleetMeta(nodes storage.Nodes) func() []map[string]string {
+	return func() []map[string]string {
+		var metas []map[string]string
+		nodes.Iter(func(n node.Node) error {
+			metas = append(metas, n.Meta)
+			return nil
+		})
+		return metas
+	}
+}
+
+// reserveCapacity sets the capacity of the node reserved for the system.
+// Configured reservations take precedence over measured ones
+func reserveCapacity(nd *node.Node, logger *zap.Logger) {
+	if *measureRes > 0 {
+		err := nd.MeasureReserved(node.DefaultCgroupRoot, node.DefaultSystemSlices, *measureRes)
+		if err != nil {
+			logger.Info("failed to measure reserved capacity", zap.Error(err))
+		}
+	}
+	if *reservedCPU > 0 && nd.CPUs > 0 {
+		nd.ReservedCPUShares = uint64(*reservedCPU * float64(nd.CPUShares) / float64(nd.CPUs))
+	}
+	if *reservedMem > 0 {
+		nd.ReservedMemory = *reservedMem << 20
+	}
+}
+
+func makePricer(nd *node.Node, logger *zap.Logger) (pricing.Provider, error) {
+	var (
+		primary pricing.Provider
+		err     error
+	)
+	switch nd.Meta[node.CloudTag] {
+	case "azure":
+		primary, err = pricing.NewAzurePricer(*azurePrices)
+		if err != nil {
+			return nil, err
+		}
+	case "gcp":
+		// There is no gcp pricer so the static prices are authoritative
+		if *staticPrices == "" {
+			logger.Warn("no gcp prices without -static-prices, containers will not be priced")
+			return pricing.NewChainProvider(), nil
+		}
+		static, err := pricing.LoadStaticPricer(*staticPrices)
+		if err != nil {
+			return nil, err
+		}
+		return pricing.NewChainProvider(pricing.ChainLink{Provider: static, Confidence: pricing.ConfidenceHigh}), nil
+	default:
+		if nd.Meta[node.LifecycleTag] == node.LifecycleSpot {
+			primary = pricing.NewAWSSpotPricer()
+		} else {
+			primary = pricing.NewAWSOnDemandPricer()
+		}
+	}
+
+	links := []pricing.ChainLink{{Provider: primary, Confidence: pricing.ConfidenceHigh}}
+	if *priceCache != "" {
+		cache, err := pricing.NewFileCache(*priceCache)
+		if err != nil {
+			return nil, err
+		}
+		links = []pricing.ChainLink{
+			{Provider: cache.Record(primary), Confidence: pricing.ConfidenceHigh},
+			{Provider: cache, Confidence: pricing.ConfidenceMedium},
+		}
+	}
+	if *staticPrices != "" {
+		static, err := pricing.LoadStaticPricer(*staticPrices)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, pricing.ChainLink{Provider: static, Confidence: pricing.ConfidenceLow})
+	}
+	return pricing.NewChainProvider(links...), nil
+}
+
+// makeCollector returns the kubelet pod collector if a kubelet is set and
+// the docker container collector otherwise, combined into allocations in
+// nomad mode
+func makeCollector(nd *node.Node, logger *zap.Logger) (metermaid.CCollector, error) {
+	if *kubeletURL == "" {
+		cc, err := metermaid.NewCCollector(*statsInt, logger)
+		if err != nil || !*nomadMode {
+			return cc, err
+		}
+		var client *nomad.Client
+		if *nomadAddr != "" {
+			client = nomad.NewClient(nomad.Config{Addr: *nomadAddr, Token: *nomadToken})
+		}
+		return metermaid.NewNomadCollector(cc, client, logger), nil
+	}
+
+	conf := kubelet.Config{URL: *kubeletURL, Insecure: *kubeletTLS}
+	// The default token only exists in a pod
+	if _, err := os.Stat(*kubeletToken); err == nil {
+		conf.TokenFile = *kubeletToken
+	}
+	client, err := kubelet.NewClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return metermaid.NewKubeletCollector(client, nd, kubelet.Minimum{CPU: *kubeletCPU, Memory: *kubeletMem * (1 << 20)}, *kubeletPoll, *statsInt, logger)
 }
 
 func main() {
+	if flag.Arg(0) == "snapshot" {
+		os.Exit(runSnapshotCmd(flag.Args()[1:]))
+	}
+
 	logger, _ := zap.NewDevelopment()
-	nd := makeNode()
+	nd, err := makeNode()
+	if err != nil {
+		logger.Info("failed to discover node metadata", zap.Error(err))
+	}
+	reserveCapacity(nd, logger)
 	logger.Info("node stats",
 		zap.Uint64("cpu", nd.CPUShares),
 		zap.Uint64("memory", nd.Memory),
+		zap.Uint64("allocatable-cpu", nd.AllocatableCPUShares()),
+		zap.Uint64("allocatable-memory", nd.AllocatableMemory()),
 		zap.Time("bootime", time.Unix(0, int64(nd.BootTime))),
 	)
 
-	cc, err := metermaid.NewCCollector(logger)
+	cc, err := makeCollector(nd, logger)
 	if err != nil {
 		logger.Fatal("failed to initialize metermaid", zap.Error(err))
 	}
@@ -139,20 +302,88 @@ func main() {
 		Node:             nd,
 		ContainerStorage: storage.NewInmemContainers(),
 		Collector:        cc,
+		StoragePricer:    pricing.NewEBSPricer(nil),
+		IOWeight:         *ioWeight,
 		Logger:           logger,
 	}
 
-	if _, ok := nd.Meta[node.SpotTag]; ok {
-		conf.Pricer = pricing.NewAWSSpotPricer()
+	if *retainAge > 0 || *retainCount > 0 {
+		conf.Retention = &storage.RetentionPolicy{
+			MaxAge:         *retainAge,
+			MaxCount:       *retainCount,
+			MaxLabelValues: *retainValues,
+		}
+		if *retainLabels != "" {
+			conf.Retention.AggregateLabels = strings.Split(*retainLabels, ",")
+		}
+	}
+
+	conf.Pricer, err = makePricer(nd, logger)
+	if err != nil {
+		logger.Fatal("failed to initialize pricer", zap.Error(err))
+	}
+
+	if *egressRates != "" {
+		if conf.EgressRates, err = pricing.LoadEgressRates(*egressRates); err != nil {
+			logger.Fatal("failed to load egress rates", zap.Error(err))
+		}
+	} else {
+		rates := pricing.DefaultEgressRates
+		conf.EgressRates = &rates
+	}
+	if *egressClass != "" {
+		if err = conf.EgressRates.SetDefault(*egressClass); err != nil {
+			logger.Fatal("invalid default egress class", zap.Error(err))
+		}
+	}
+	logger.Info("egress", zap.String("default", conf.EgressRates.Default))
+
+	if *powerModel != "" {
+		if conf.Energy, err = energy.LoadModel(*powerModel); err != nil {
+			logger.Fatal("failed to load power model", zap.Error(err))
+		}
 	} else {
-		conf.Pricer = pricing.NewAWSOnDemandPricer()
+		conf.Energy = energy.DefaultModel()
+	}
+
+	if *exchRates != "" {
+		if conf.ExchangeRates, err = pricing.LoadExchangeRates(*exchRates); err != nil {
+			logger.Fatal("failed to load exchange rates", zap.Error(err))
+		}
+	}
+
+	gsp, gpool, gspDel := initGossip(logger, nd)
+	ldgr := gspDel.ledger
+	nodes := storage.NewGossipNodes(gpool)
+	http.Handle("/node/", api.NewNodeHandler("/node", nodes, gspDel.MetaHistories))
+
+	if *commitments != "" {
+		if conf.Commitments, err = pricing.LoadCommitments(*commitments); err != nil {
+			logger.Fatal("failed to load commitments", zap.Error(err))
+		}
+		conf.Commitments.Fleet = fleetMeta(nodes)
 	}
 
 	mm := metermaid.New(conf)
 
-	gsp, gpool := initGossip(logger, nd)
-	napi := &nodeAPI{"/node", storage.NewGossipNodes(gpool)}
-	http.Handle("/node/", napi)
+	if *metaRefresh > 0 {
+		go refreshNode(mm, gspDel, gpool, *metaRefresh, logger)
+	}
+
+	state := snapshot.State{
+		Node:       mm.Node,
+		Containers: mm.Containers(),
+		Prices:     mm.Prices(),
+		Nodes:      nodes,
+	}
+	if *restoreFile != "" {
+		restoreFromFile(*restoreFile, state, gpool, logger)
+	}
+	http.Handle("/snapshot", api.NewSnapshotHandler(state, logger))
+
+	go ldgr.Follow(mm.Containers())
+	go ldgr.Run(*ledgerInt)
+	http.Handle("/ledger", api.NewLedgerHandler(ldgr))
 
 	mmAPI := api.New(mm, logger)
 	go mmAPI.Serve(gsp.ListenTCP())
@@ -161,5 +392,6 @@ func main() {
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	<-sigs
+	ldgr.Stop()
 	cc.Stop()
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/euforia/gossip"
+	"github.com/euforia/metermaid"
+	"github.com/euforia/metermaid/node"
+)
+
+// nodeUpdateTimeout bounds waiting for the updated node meta to be
+// broadcast
+const nodeUpdateTimeout = 10 * time.Second
+
+// refreshNode periodically rediscovers the node metadata and capacity e.g.
+// instance tags added after startup.  Changes are applied to pricing and
+// propagated to the cluster through the gossip node meta.  Nothing is
+// changed if the metadata cannot be discovered or the cloud is no longer
+// detected as both are most likely transient
+func refreshNode(mm metermaid.Metermaid, del *GossipDelegate, pool *gossip.Pool, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cur := mm.Node()
+		fresh, err := makeNode()
+		if err != nil {
+			logger.Info("failed to rediscover node", zap.Error(err))
+			continue
+		}
+		if fresh.Meta[node.CloudTag] != cur.Meta[node.CloudTag] {
+			logger.Info("node cloud not rediscovered", zap.String("cloud", cur.Meta[node.CloudTag]))
+			continue
+		}
+		fresh.Name, fresh.Address = cur.Name, cur.Address
+		fresh.ReservedCPUShares, fresh.ReservedMemory = cur.ReservedCPUShares, cur.ReservedMemory
+
+		if fresh.Meta.Equal(cur.Meta) && fresh.CPUShares == cur.CPUShares && fresh.Memory == cur.Memory {
+			continue
+		}
+
+		mm.SetNode(fresh)
+		del.setNode(*fresh)
+		if err := pool.UpdateNode(nodeUpdateTimeout); err != nil {
+			logger.Info("failed to propagate node update", zap.Error(err))
+		}
+		logger.Info("node rediscovered",
+			zap.Uint64("cpu", fresh.CPUShares),
+			zap.Uint64("memory", fresh.Memory),
+			zap.Any("tags", fresh.Meta),
+		)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/euforia/gossip"
+	"go.uber.org/zap"
+
+	"github.com/euforia/metermaid/snapshot"
+)
+
+const snapshotUsage = `usage: metermaid snapshot save <agent address> [file]
+       metermaid snapshot restore <agent address> [file]
+
+Streams a snapshot of a running agent to the file or stdout, or restores
+one from the file or stdin into a running agent.
+`
+
+// runSnapshotCmd runs the snapshot sub command returning the exit code
+func runSnapshotCmd(args []string) int {
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprint(os.Stderr, snapshotUsage)
+		return 2
+	}
+	url := "http://" + args[1] + "/snapshot"
+
+	var err error
+	switch args[0] {
+	case "save":
+		out := os.Stdout
+		if len(args) == 3 {
+			if out, err = os.Create(args[2]); err != nil {
+				break
+			}
+			defer out.Close()
+		}
+		err = saveSnapshot(url, out)
+
+	case "restore":
+		in := os.Stdin
+		if len(args) == 3 {
+			if in, err = os.Open(args[2]); err != nil {
+				break
+			}
+			defer in.Close()
+		}
+		err = restoreSnapshot(url, in)
+
+	default:
+		fmt.Fprint(os.Stderr, snapshotUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func saveSnapshot(url string, w io.Writer) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("snapshot failed: %s", resp.Status)
+	}
+
+	// Verify while streaming so a truncated snapshot is reported
+	pr, pw := io.Pipe()
+	verified := make(chan error, 1)
+	go func() {
+		_, err := snapshot.Read(pr)
+		io.Copy(io.Discard, pr)
+		verified <- err
+	}()
+
+	_, err = io.Copy(io.MultiWriter(w, pw), resp.Body)
+	pw.CloseWithError(err)
+	if verr := <-verified; err == nil {
+		err = verr
+	}
+	return err
+}
+
+func restoreSnapshot(url string, r io.Reader) error {
+	resp, err := http.Post(url, "application/x-ndjson", r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("restore failed: %s: %s", resp.Status, body)
+	}
+	fmt.Println(string(body))
+	return nil
+}
+
+// restoreFromFile restores the snapshot file into the state at startup and
+// rejoins the nodes it knew about
+func restoreFromFile(path string, state snapshot.State, pool *gossip.Pool, logger *zap.Logger) {
+	fh, err := os.Open(path)
+	if err != nil {
+		logger.Fatal("failed to open snapshot", zap.Error(err))
+	}
+	defer fh.Close()
+
+	snap, err := snapshot.Read(fh)
+	if err != nil {
+		logger.Fatal("failed to read snapshot", zap.Error(err))
+	}
+	prices, err := snap.Restore(state)
+	if err != nil {
+		logger.Fatal("failed to restore snapshot", zap.Error(err))
+	}
+	logger.Info("snapshot restored",
+		zap.String("node", snap.Node.Name),
+		zap.Time("created", snap.Created),
+		zap.Int("containers", len(snap.Containers)),
+		zap.Bool("prices", prices),
+	)
+
+	if addrs := snap.Addresses(); len(addrs) > 0 && *joinPeer == "" {
+		if _, err = pool.Join(addrs); err != nil {
+			logger.Info("failed to join snapshot nodes", zap.Error(err))
+		}
+	}
+}
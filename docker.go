@@ -2,8 +2,10 @@ package metermaid
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"os"
+	"strings"
 	"time"
 
 	dtypes "github.com/docker/docker/api/types"
@@ -42,11 +44,24 @@ func (client *DockerClient) Container(ctx context.Context, id string) (*types.Co
 	details, err := client.Client.ContainerInspect(ctx, id)
 	if err == nil {
 		cont := &types.Container{
-			ID:        details.ID,
-			Name:      details.Name,
-			Labels:    details.Config.Labels,
-			CPUShares: details.HostConfig.CPUShares,
-			Memory:    details.HostConfig.Memory,
+			ID:     details.ID,
+			Name:   details.Name,
+			Labels: details.Config.Labels,
+			Memory: details.HostConfig.Memory,
+			CPU: &types.CPUReservation{
+				NanoCPUs: details.HostConfig.NanoCPUs,
+				Quota:    details.HostConfig.CPUQuota,
+				Period:   details.HostConfig.CPUPeriod,
+				Cpuset:   details.HostConfig.CpusetCpus,
+				Shares:   details.HostConfig.CPUShares,
+			},
+		}
+
+		for _, m := range details.Mounts {
+			cont.Mounts = append(cont.Mounts, types.Mount{
+				Source:      m.Source,
+				Destination: m.Destination,
+			})
 		}
 
 		createdAt, _ := time.Parse(time.RFC3339Nano, details.Created)
@@ -67,6 +82,58 @@ func (client *DockerClient) Container(ctx context.Context, id string) (*types.Co
 	return nil, err
 }
 
+// Stats returns a single resource usage sample for the container by the given id
+func (client *DockerClient) Stats(ctx context.Context, id string) (*types.ContainerStats, error) {
+	resp, err := client.Client.ContainerStats(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var sj dtypes.StatsJSON
+	if err = json.NewDecoder(resp.Body).Decode(&sj); err != nil {
+		return nil, err
+	}
+
+	stats := &types.ContainerStats{ID: id, Timestamp: sj.Read.UnixNano()}
+	for _, ns := range sj.Networks {
+		stats.NetRxBytes += ns.RxBytes
+		stats.NetTxBytes += ns.TxBytes
+	}
+	stats.BlkReadBytes, stats.BlkWriteBytes = sumBlkio(sj.BlkioStats.IoServiceBytesRecursive)
+	stats.BlkReadOps, stats.BlkWriteOps = sumBlkio(sj.BlkioStats.IoServicedRecursive)
+
+	return stats, nil
+}
+
+// WritableLayerSize returns the size of the writable layer of the container
+// by the given id.  The daemon walks the layer to compute it so it should be
+// called sparingly
+func (client *DockerClient) WritableLayerSize(ctx context.Context, id string) (int64, error) {
+	details, _, err := client.Client.ContainerInspectWithRaw(ctx, id, true)
+	if err != nil {
+		return 0, err
+	}
+	if details.SizeRw == nil {
+		return 0, nil
+	}
+	return *details.SizeRw, nil
+}
+
+// sumBlkio returns the read and write totals across all devices. cgroup v1
+// capitalizes the op while v2 does not.
+func sumBlkio(entries []dtypes.BlkioStatEntry) (read, write uint64) {
+	for _, e := range entries {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			read += e.Value
+		case "write":
+			write += e.Value
+		}
+	}
+	return
+}
+
 // Containers returns a list of running containers.  A complete or partial list
 // is returned depending on the error. The error returned is the last error occurred
 func (client *DockerClient) Containers(ctx context.Context) ([]*types.Container, error) {
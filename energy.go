@@ -0,0 +1,81 @@
+package metermaid
+
+import (
+	"time"
+
+	"github.com/euforia/metermaid/energy"
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/storage"
+	"github.com/euforia/metermaid/tsdb"
+	"github.com/euforia/metermaid/types"
+)
+
+// Series metrics holding running totals for each container alongside the
+// dollar cost
+const (
+	// MetricUnitsBurned is the series metric for the container cost
+	MetricUnitsBurned = "units.burned"
+	// MetricEnergyKWh is the series metric for the container energy use
+	MetricEnergyKWh = "energy.kwh"
+	// MetricCarbonCO2e is the series metric for the container emissions in
+	// grams
+	MetricCarbonCO2e = "carbon.co2e"
+)
+
+// powerModel returns the power model of the node
+func (mm *meterMaid) powerModel() energy.PowerModel {
+	nd := mm.Node()
+	return mm.energy.PowerModel(nd.Meta["InstanceType"], nd.NumCPU())
+}
+
+// computeEnergy estimates the energy used and carbon emitted by the
+// container.  The container is attributed its cpu share of the node cpu
+// power and the power of its memory.  Containers without a cpu reservation
+// are attributed a single cpu, the default weight, rather than the whole
+// node so their estimates do not add up to more than the node draws
+func (mm *meterMaid) computeEnergy(c types.Container) energy.Estimate {
+	if mm.energy == nil {
+		return energy.Estimate{}
+	}
+
+	var (
+		nd         = mm.Node()
+		pm         = mm.powerModel()
+		rCPU, rMem = mm.utilizationPercent(c)
+	)
+	// Unreserved docker containers are given the whole node by CPUFraction
+	if c.CPUShares <= 0 || c.CPUSource == node.CPUSourceNone {
+		rCPU = 1 / float64(nd.NumCPU())
+	}
+	var (
+		memGB = rMem * float64(nd.Memory) / 1e9
+		watts = rCPU*pm.Watts(mm.energy.Utilization, 0) + pm.MemoryWattsPerGB*memGB
+		d     = time.Duration(allocEnd(c) - c.Create)
+	)
+	return mm.energy.Estimate(watts, d, nd.Meta["Region"])
+}
+
+// nodeEnergy estimates the energy used and carbon emitted by the whole node
+// between start and end
+func (mm *meterMaid) nodeEnergy(start, end time.Time) energy.Estimate {
+	if mm.energy == nil {
+		return energy.Estimate{}
+	}
+	nd := mm.Node()
+	watts := mm.powerModel().Watts(mm.energy.Utilization, nd.Memory)
+	return mm.energy.Estimate(watts, end.Sub(start), nd.Meta["Region"])
+}
+
+// appendUnits records the running cost, energy and carbon totals of the
+// container as series
+func (mm *meterMaid) appendUnits(c types.Container) {
+	ts := uint64(allocEnd(c))
+	for metric, value := range map[string]float64{
+		MetricUnitsBurned: c.UnitsBurned,
+		MetricEnergyKWh:   c.EnergyKWh,
+		MetricCarbonCO2e:  c.CarbonCO2e,
+	} {
+		mm.series.Append(storage.SeriesName(c.ID, metric),
+			tsdb.DataPoint{Timestamp: ts, Value: value})
+	}
+}
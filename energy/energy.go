@@ -0,0 +1,125 @@
+// Package energy estimates power use and carbon emissions of nodes and the
+// containers running on them
+package energy
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// PowerModel describes the power draw of an instance
+type PowerModel struct {
+	// Watts drawn by the cpus when idle
+	IdleWatts float64
+	// Watts drawn by the cpus at full utilization
+	MaxWatts float64
+	// Watts drawn per GB of memory
+	MemoryWattsPerGB float64
+}
+
+// Watts returns the power draw at the given cpu utilization between 0 and 1
+// with the given amount of memory in bytes
+func (pm PowerModel) Watts(utilization float64, memory uint64) float64 {
+	return pm.IdleWatts + (pm.MaxWatts-pm.IdleWatts)*utilization +
+		pm.MemoryWattsPerGB*float64(memory)/1e9
+}
+
+// Per vCPU coefficients used when an instance type is not in the model.
+// These are averages across aws hardware.
+const (
+	defaultIdleWattsPerVCPU = 0.74
+	defaultMaxWattsPerVCPU  = 3.5
+	defaultMemoryWattsPerGB = 0.392
+	// Grid intensity used for unknown regions in gCO2e/kWh
+	defaultGridIntensity = 400
+)
+
+// DefaultGridIntensity holds the approximate grid carbon intensity of aws
+// regions in gCO2e/kWh
+var DefaultGridIntensity = map[string]float64{
+	"us-east-1":      379,
+	"us-east-2":      410,
+	"us-west-1":      190,
+	"us-west-2":      136,
+	"ca-central-1":   130,
+	"eu-west-1":      279,
+	"eu-west-2":      225,
+	"eu-central-1":   311,
+	"eu-north-1":     9,
+	"ap-southeast-1": 408,
+	"ap-southeast-2": 790,
+	"ap-northeast-1": 506,
+}
+
+// Model holds the power models by instance type and grid carbon intensity
+// by region
+type Model struct {
+	Instances map[string]PowerModel
+	// gCO2e per kWh by region
+	GridIntensity map[string]float64
+	// Average cpu utilization assumed for the node between 0 and 1. Only
+	// allocations are known, not actual usage
+	Utilization float64
+}
+
+// DefaultModel returns a model using default coefficients for all instance
+// types
+func DefaultModel() *Model {
+	return &Model{
+		Instances:     make(map[string]PowerModel),
+		GridIntensity: DefaultGridIntensity,
+		Utilization:   0.5,
+	}
+}
+
+// LoadModel loads the model from the json file at the given path. Unset
+// fields take their defaults
+func LoadModel(path string) (*Model, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	m := DefaultModel()
+	if err = json.NewDecoder(fh).Decode(m); err == nil {
+		return m, nil
+	}
+	return nil, err
+}
+
+// PowerModel returns the power model for the instance type falling back to
+// one derived from the number of vcpus
+func (m *Model) PowerModel(instanceType string, vcpus int) PowerModel {
+	if pm, ok := m.Instances[instanceType]; ok {
+		return pm
+	}
+	return PowerModel{
+		IdleWatts:        defaultIdleWattsPerVCPU * float64(vcpus),
+		MaxWatts:         defaultMaxWattsPerVCPU * float64(vcpus),
+		MemoryWattsPerGB: defaultMemoryWattsPerGB,
+	}
+}
+
+// Intensity returns the grid intensity in gCO2e/kWh for the region
+func (m *Model) Intensity(region string) float64 {
+	if gi, ok := m.GridIntensity[region]; ok {
+		return gi
+	}
+	return defaultGridIntensity
+}
+
+// Estimate holds the energy used and carbon emitted over a period
+type Estimate struct {
+	KWh float64
+	// Grams of CO2 equivalent
+	CO2e float64
+}
+
+// Estimate returns the energy and carbon for drawing the given watts for the
+// duration in the region
+func (m *Model) Estimate(watts float64, d time.Duration, region string) Estimate {
+	kwh := watts * d.Hours() / 1000
+	return Estimate{KWh: kwh, CO2e: kwh * m.Intensity(region)}
+}
@@ -0,0 +1,30 @@
+package energy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PowerModel(t *testing.T) {
+	pm := PowerModel{IdleWatts: 10, MaxWatts: 30, MemoryWattsPerGB: 0.5}
+	assert.Equal(t, 10.0, pm.Watts(0, 0))
+	assert.Equal(t, 20.0, pm.Watts(0.5, 0))
+	assert.Equal(t, 35.0, pm.Watts(1, 10e9))
+}
+
+func Test_Model(t *testing.T) {
+	m := DefaultModel()
+	m.Instances["m5.large"] = PowerModel{IdleWatts: 5, MaxWatts: 15}
+
+	assert.Equal(t, 5.0, m.PowerModel("m5.large", 2).IdleWatts)
+	assert.InDelta(t, 4*defaultMaxWattsPerVCPU, m.PowerModel("unknown", 4).MaxWatts, 1e-9)
+
+	est := m.Estimate(100, 10*time.Hour, "us-east-1")
+	assert.InDelta(t, 1.0, est.KWh, 1e-9)
+	assert.InDelta(t, 379.0, est.CO2e, 1e-9)
+
+	est = m.Estimate(100, 10*time.Hour, "unknown")
+	assert.InDelta(t, defaultGridIntensity, est.CO2e, 1e-9)
+}
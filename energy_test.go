@@ -0,0 +1,81 @@
+package metermaid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/euforia/metermaid/energy"
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/pricing"
+	"github.com/euforia/metermaid/storage"
+	"github.com/euforia/metermaid/types"
+)
+
+func Test_meterMaid_computeEnergy(t *testing.T) {
+	mm := &meterMaid{
+		node: &node.Node{
+			CPUs:      4,
+			CPUShares: 8000,
+			Memory:    16e9,
+			Meta:      map[string]string{"InstanceType": "m5.xlarge", "Region": "us-west-2"},
+		},
+		energy: energy.DefaultModel(),
+	}
+	mm.energy.Instances["m5.xlarge"] = energy.PowerModel{IdleWatts: 10, MaxWatts: 30}
+	h := int64(time.Hour)
+
+	// Half the node cpu and no memory power
+	est := mm.computeEnergy(types.Container{CPUShares: 4000, Memory: 8e9, Create: h, Destroy: 2 * h})
+	assert.InDelta(t, 0.5*20/1000, est.KWh, 1e-9)
+
+	// Without a reservation only a single cpu of the node is attributed
+	est = mm.computeEnergy(types.Container{Memory: 8e9, Create: h, Destroy: 2 * h})
+	assert.InDelta(t, 0.25*20/1000, est.KWh, 1e-9)
+	assert.InDelta(t, est.KWh*136, est.CO2e, 1e-9)
+
+	// Default model from the node cpus when the instance type is unknown
+	mm.node.Meta = map[string]string{"InstanceType": "other"}
+	assert.Equal(t, energy.DefaultModel().PowerModel("", 4), mm.powerModel())
+}
+
+func Test_meterMaid_run_energy(t *testing.T) {
+	nd := &node.Node{
+		CPUs:      4,
+		CPUShares: 8000,
+		Memory:    16e9,
+		Meta:      map[string]string{"InstanceType": "m5.xlarge", "Region": "us-west-2"},
+	}
+	mm := &meterMaid{
+		node:      nd,
+		history:   node.NewMetaHistory(nd.Meta, time.Unix(0, 0)),
+		cpuWeight: 0.5,
+		memWeight: 0.5,
+		pp:        pricing.NewPricer(pricing.NewStaticPricer(map[string]float64{"m5.xlarge": 0.192}), *nd, zap.NewNop()),
+		mounts:    make(map[string]map[string][2]int64),
+		energy:    energy.DefaultModel(),
+		cstore:    storage.NewInmemContainers(),
+		series:    storage.NewInmemSeries(),
+		log:       zap.NewNop(),
+	}
+	mm.energy.Instances["m5.xlarge"] = energy.PowerModel{IdleWatts: 10, MaxWatts: 30}
+	h := int64(time.Hour)
+
+	// Docker reports an empty reservation for unreserved containers
+	updates := make(chan types.Container, 2)
+	updates <- types.Container{ID: "free", CPU: &types.CPUReservation{}, Memory: 8e9, Create: h, Destroy: 2 * h}
+	updates <- types.Container{ID: "half", CPU: &types.CPUReservation{NanoCPUs: 2e9}, Memory: 8e9, Create: h, Destroy: 2 * h}
+	close(updates)
+	mm.run(updates)
+
+	free, err := mm.cstore.Get("free")
+	assert.Nil(t, err)
+	assert.Equal(t, node.CPUSourceNone, free.CPUSource)
+	assert.InDelta(t, 0.25*20/1000, free.EnergyKWh, 1e-9)
+
+	half, err := mm.cstore.Get("half")
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.5*20/1000, half.EnergyKWh, 1e-9)
+}
@@ -0,0 +1,304 @@
+package fl
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Aggregation query parameters.  These are removed from the parameters by
+// ParseAggregation so the remainder can be parsed as a query
+const (
+	// Comma separated functions e.g. agg=sum(UnitsBurned),p95(RunTime),top20(UnitsBurned)
+	AggParam = "agg"
+	// Comma separated fields to group by e.g. groupBy=service
+	GroupByParam = "groupBy"
+)
+
+// Aggregate functions.  Percentiles are p followed by the percentile e.g.
+// p95 or p99.9 and top-k is top followed by k e.g. top20
+const (
+	AggCount = "count"
+	AggSum   = "sum"
+	AggAvg   = "avg"
+	AggMin   = "min"
+	AggMax   = "max"
+	AggPct   = "p"
+	AggTop   = "top"
+)
+
+// AggFunc is a single aggregate function applied to a numeric field
+type AggFunc struct {
+	Func  string
+	Field string
+	// Percentile for p and k for top
+	Arg float64
+}
+
+func (af AggFunc) String() string {
+	name := af.Func
+	if af.Func == AggPct || af.Func == AggTop {
+		name += strconv.FormatFloat(af.Arg, 'f', -1, 64)
+	}
+	return name + "(" + af.Field + ")"
+}
+
+// TopItem is an item in a top-k result
+type TopItem struct {
+	// Value of the schema key e.g. container id
+	Key   string
+	Value float64
+}
+
+// Group holds the aggregate values of the items sharing the same values of
+// the group by fields
+type Group struct {
+	Group  map[string]string `json:",omitempty"`
+	Count  int
+	Values map[string]interface{}
+}
+
+// Aggregation holds the functions and grouping of an aggregation query
+type Aggregation struct {
+	Funcs   []AggFunc
+	GroupBy []string
+
+	schema *Schema
+}
+
+// ParseAggregation removes the aggregation parameters from params and
+// parses them.  Aggregated fields must be numeric fields in the schema. It
+// returns nil if neither parameter is given.  Grouping without functions
+// counts the items in each group
+func ParseAggregation(params map[string][]string, schema *Schema) (*Aggregation, error) {
+	aggs, groupBys := params[AggParam], params[GroupByParam]
+	delete(params, AggParam)
+	delete(params, GroupByParam)
+	if len(aggs) == 0 && len(groupBys) == 0 {
+		return nil, nil
+	}
+
+	agg := &Aggregation{schema: schema}
+	var errs []FieldError
+	for _, val := range aggs {
+		for _, fn := range parseDelimited(val, ListDelimiter) {
+			af, fe := parseAggFunc(fn, schema)
+			if fe != nil {
+				errs = append(errs, *fe)
+				continue
+			}
+			agg.Funcs = append(agg.Funcs, af)
+		}
+	}
+	for _, val := range groupBys {
+		for _, field := range parseDelimited(val, ListDelimiter) {
+			if _, _, ok := schema.Field(field); !ok {
+				errs = append(errs, FieldError{Field: field, Kind: ErrKindUnknownField, Msg: "cannot group by unknown field"})
+				continue
+			}
+			agg.GroupBy = append(agg.GroupBy, field)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return agg, nil
+}
+
+func parseAggFunc(in string, schema *Schema) (AggFunc, *FieldError) {
+	open := strings.IndexByte(in, '(')
+	if open < 0 || !strings.HasSuffix(in, ")") {
+		return AggFunc{}, &FieldError{Field: in, Kind: ErrKindBadOperator, Msg: "expected function(field)"}
+	}
+	af := AggFunc{Func: in[:open], Field: in[open+1 : len(in)-1]}
+
+	switch {
+	case af.Func == AggCount:
+		if _, _, ok := schema.Field(af.Field); af.Field != "" && !ok {
+			return af, &FieldError{Field: af.Field, Kind: ErrKindUnknownField, Operator: af.Func, Msg: "cannot count unknown field"}
+		}
+		return af, nil
+	case af.Func == AggSum, af.Func == AggAvg, af.Func == AggMin, af.Func == AggMax:
+	case strings.HasPrefix(af.Func, AggTop):
+		k, err := strconv.Atoi(af.Func[len(AggTop):])
+		if err != nil || k < 1 {
+			return af, &FieldError{Field: af.Field, Kind: ErrKindBadOperator, Operator: af.Func, Msg: "top requires a positive k e.g. top10"}
+		}
+		af.Func, af.Arg = AggTop, float64(k)
+	case strings.HasPrefix(af.Func, AggPct):
+		pct, err := strconv.ParseFloat(af.Func[len(AggPct):], 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return af, &FieldError{Field: af.Field, Kind: ErrKindBadOperator, Operator: af.Func, Msg: "percentile must be between 0 and 100 e.g. p95"}
+		}
+		af.Func, af.Arg = AggPct, pct
+	default:
+		return af, &FieldError{Field: af.Field, Kind: ErrKindBadOperator, Operator: af.Func, Msg: "unknown aggregate function"}
+	}
+
+	ft, dynamic, ok := schema.Field(af.Field)
+	switch {
+	case !ok:
+		return af, &FieldError{Field: af.Field, Kind: ErrKindUnknownField, Operator: af.Func, Msg: "cannot aggregate unknown field"}
+	case dynamic || ft == FieldString:
+		return af, &FieldError{Field: af.Field, Kind: ErrKindBadOperator, Operator: af.Func, Msg: "cannot aggregate non-numeric field"}
+	}
+	return af, nil
+}
+
+// Apply groups the items and computes the aggregate functions of each
+// group.  Items missing a group by field are grouped under an empty value.
+// Groups are sorted by their group values
+func (agg *Aggregation) Apply(items []Valuer) []Group {
+	groups := make(map[string][]Valuer)
+	keys := make(map[string]map[string]string)
+	tuples := make(map[string][]string)
+	for _, item := range items {
+		gvals := make(map[string]string, len(agg.GroupBy))
+		parts := make([]string, len(agg.GroupBy))
+		for i, field := range agg.GroupBy {
+			if v := item.FieldValue(field); v != nil {
+				parts[i] = formatValue(v)
+			}
+			gvals[field] = parts[i]
+		}
+		gk := groupKey(parts)
+		groups[gk] = append(groups[gk], item)
+		keys[gk] = gvals
+		tuples[gk] = parts
+	}
+
+	gks := make([]string, 0, len(groups))
+	for gk := range groups {
+		gks = append(gks, gk)
+	}
+	sort.Slice(gks, func(i, j int) bool {
+		a, b := tuples[gks[i]], tuples[gks[j]]
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+
+	out := make([]Group, 0, len(gks))
+	for _, gk := range gks {
+		group := Group{
+			Count:  len(groups[gk]),
+			Values: make(map[string]interface{}, len(agg.Funcs)),
+		}
+		if len(agg.GroupBy) > 0 {
+			group.Group = keys[gk]
+		}
+		for _, af := range agg.Funcs {
+			group.Values[af.String()] = agg.compute(af, groups[gk])
+		}
+		out = append(out, group)
+	}
+	return out
+}
+
+// groupKey returns a key unique to the tuple of group values.  Each value
+// is prefixed by its length as values may hold any character
+func groupKey(parts []string) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		sb.WriteString(strconv.Itoa(len(p)))
+		sb.WriteByte(':')
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
+
+func (agg *Aggregation) compute(af AggFunc, items []Valuer) interface{} {
+	if af.Func == AggCount {
+		if af.Field == "" {
+			return len(items)
+		}
+		var n int
+		for _, item := range items {
+			if item.FieldValue(af.Field) != nil {
+				n++
+			}
+		}
+		return n
+	}
+
+	vals := make([]float64, 0, len(items))
+	tops := make([]TopItem, 0, len(items))
+	for _, item := range items {
+		v, ok := toFloat(item.FieldValue(af.Field))
+		if !ok {
+			continue
+		}
+		vals = append(vals, v)
+		if af.Func == AggTop && agg.schema.Key != "" {
+			tops = append(tops, TopItem{Key: formatValue(item.FieldValue(agg.schema.Key)), Value: v})
+		}
+	}
+
+	switch af.Func {
+	case AggTop:
+		sort.SliceStable(tops, func(i, j int) bool { return tops[i].Value > tops[j].Value })
+		if k := int(af.Arg); k < len(tops) {
+			tops = tops[:k]
+		}
+		return tops
+	case AggSum, AggAvg:
+		var sum float64
+		for _, v := range vals {
+			sum += v
+		}
+		if af.Func == AggAvg {
+			if len(vals) == 0 {
+				return nil
+			}
+			return sum / float64(len(vals))
+		}
+		return sum
+	}
+
+	if len(vals) == 0 {
+		return nil
+	}
+	sort.Float64s(vals)
+	switch af.Func {
+	case AggMin:
+		return vals[0]
+	case AggMax:
+		return vals[len(vals)-1]
+	}
+	return percentile(vals, af.Arg)
+}
+
+// percentile returns the linearly interpolated percentile of sorted values
+func percentile(sorted []float64, pct float64) float64 {
+	rank := pct / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	return 0, false
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package fl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Aggregation(t *testing.T) {
+	params := map[string][]string{
+		"agg":     []string{"count(),sum(CPUShares),avg(CPUShares),min(CPUShares),max(CPUShares),p50(CPUShares),top1(CPUShares)"},
+		"groupBy": []string{"team"},
+		"Name":    []string{"ne:x"},
+	}
+	agg, err := ParseAggregation(params, testListSchema)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(params))
+
+	groups := agg.Apply(testItems())
+	assert.Equal(t, 3, len(groups))
+	assert.Equal(t, map[string]string{"team": ""}, groups[0].Group)
+	assert.Equal(t, "data", groups[1].Group["team"])
+
+	infra := groups[2]
+	assert.Equal(t, 2, infra.Count)
+	assert.Equal(t, 2, infra.Values["count()"])
+	assert.Equal(t, 768.0, infra.Values["sum(CPUShares)"])
+	assert.Equal(t, 384.0, infra.Values["avg(CPUShares)"])
+	assert.Equal(t, 256.0, infra.Values["min(CPUShares)"])
+	assert.Equal(t, 512.0, infra.Values["max(CPUShares)"])
+	assert.Equal(t, 384.0, infra.Values["p50(CPUShares)"])
+	assert.Equal(t, []TopItem{{Key: "c", Value: 512}}, infra.Values["top1(CPUShares)"])
+
+	// Values holding any character do not collide
+	agg, err = ParseAggregation(map[string][]string{"agg": []string{"count()"}, "groupBy": []string{"Name,team"}}, testListSchema)
+	assert.Nil(t, err)
+	groups = agg.Apply([]Valuer{
+		&testItem{"a\x1fb", 1, ""},
+		&testItem{"a", 1, "b\x1f"},
+	})
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, "a", groups[0].Group["Name"])
+
+	agg, err = ParseAggregation(map[string][]string{}, testListSchema)
+	assert.Nil(t, err)
+	assert.Nil(t, agg)
+}
+
+func Test_ParseAggregation_Errors(t *testing.T) {
+	_, err := ParseAggregation(map[string][]string{
+		"agg":     []string{"sum(Name),p101(CPUShares),top0(CPUShares),median(CPUShares),sum(team),max(Nmae),count"},
+		"groupBy": []string{"Team"},
+	}, testListSchema)
+	verr, ok := err.(*ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, 8, len(verr.Errors))
+}
+
+func Test_percentile(t *testing.T) {
+	vals := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.Equal(t, 1.0, percentile(vals, 0))
+	assert.Equal(t, 10.0, percentile(vals, 100))
+	assert.InDelta(t, 9.55, percentile(vals, 95), 1e-9)
+}
@@ -0,0 +1,433 @@
+package fl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Matcher is implemented by anything that can be filtered on named fields
+// e.g. containers and nodes
+type Matcher interface {
+	// MatchField returns true if the named field matches all filters
+	MatchField(name string, filters ...Filter) bool
+}
+
+// Expr is a node in a parsed boolean expression
+type Expr interface {
+	// Eval returns true if the expression matches
+	Eval(m Matcher) bool
+	String() string
+}
+
+// AndExpr matches if both sides match
+type AndExpr struct {
+	Left, Right Expr
+}
+
+// Eval satisfies the Expr interface
+func (e *AndExpr) Eval(m Matcher) bool {
+	return e.Left.Eval(m) && e.Right.Eval(m)
+}
+
+func (e *AndExpr) String() string {
+	return "(" + e.Left.String() + " AND " + e.Right.String() + ")"
+}
+
+// OrExpr matches if either side matches
+type OrExpr struct {
+	Left, Right Expr
+}
+
+// Eval satisfies the Expr interface
+func (e *OrExpr) Eval(m Matcher) bool {
+	return e.Left.Eval(m) || e.Right.Eval(m)
+}
+
+func (e *OrExpr) String() string {
+	return "(" + e.Left.String() + " OR " + e.Right.String() + ")"
+}
+
+// NotExpr matches if the inner expression does not
+type NotExpr struct {
+	Expr Expr
+}
+
+// Eval satisfies the Expr interface
+func (e *NotExpr) Eval(m Matcher) bool {
+	return !e.Expr.Eval(m)
+}
+
+func (e *NotExpr) String() string {
+	return "NOT " + e.Expr.String()
+}
+
+// FieldExpr matches a single field against a filter
+type FieldExpr struct {
+	Field  string
+	Filter Filter
+}
+
+// Eval satisfies the Expr interface
+func (e *FieldExpr) Eval(m Matcher) bool {
+	return m.MatchField(e.Field, e.Filter)
+}
+
+func (e *FieldExpr) String() string {
+	val := strings.Join(e.Filter.Values, ListDelimiter)
+	if e.Filter.Operator != NoOp {
+		val = e.Filter.Operator + ":" + val
+	}
+	return e.Field + "=" + val
+}
+
+// TrueExpr always matches.  It is the expression of an empty query
+type TrueExpr struct{}
+
+// Eval satisfies the Expr interface
+func (e TrueExpr) Eval(m Matcher) bool { return true }
+
+func (e TrueExpr) String() string { return "true" }
+
+// Expr returns the query as an expression AND'ing all fields and filters.
+// Fields are sorted so the result is deterministic
+func (query Query) Expr() Expr {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var expr Expr
+	for _, k := range keys {
+		for _, filter := range query[k] {
+			fe := &FieldExpr{Field: k, Filter: filter}
+			if expr == nil {
+				expr = fe
+			} else {
+				expr = &AndExpr{Left: expr, Right: fe}
+			}
+		}
+	}
+	if expr == nil {
+		return TrueExpr{}
+	}
+	return expr
+}
+
+// ExprParam is the query parameter holding a boolean expression
+const ExprParam = "q"
+
+// ParseQueryExpr parses query parameters into a single expression. The
+// expression in the q parameter, if any, is AND'ed with the remaining
+// parameters which are parsed as with ParseQuery.  If schema is not nil the
+// whole expression is validated against it
+func ParseQueryExpr(in map[string][]string, schema *Schema) (Expr, error) {
+	params := make(map[string][]string, len(in))
+	for k, v := range in {
+		if k != ExprParam {
+			params[k] = v
+		}
+	}
+	// Invalid patterns are reported by the schema in more detail
+	query, err := ParseQuery(params, nil)
+	if err != nil && schema == nil {
+		return nil, err
+	}
+	expr := query.Expr()
+
+	for _, q := range in[ExprParam] {
+		if strings.TrimSpace(q) == "" {
+			continue
+		}
+		parsed, err := ParseExpr(q)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := expr.(TrueExpr); ok {
+			expr = parsed
+		} else {
+			expr = &AndExpr{Left: expr, Right: parsed}
+		}
+	}
+
+	if schema != nil {
+		if err := schema.ValidateExpr(expr); err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+// SyntaxError is returned when an expression cannot be parsed
+type SyntaxError struct {
+	// Byte offset into the expression
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// comparison operators to filter operators.  = and == defer to the operator
+// prefix in the value if any e.g. name=prefix:batch-
+var exprOps = map[string]string{
+	"=":  NoOp,
+	"==": NoOp,
+	"!=": OpNotEqual,
+	"<":  OpLess,
+	"<=": OpLessEqual,
+	">":  OpGreater,
+	">=": OpGreaterEqual,
+}
+
+// ParseExpr parses a boolean expression of field comparisons joined with
+// AND, OR, NOT and parentheses e.g.
+//
+//	team=infra AND (service=api OR CPUShares>1024) AND NOT env=dev
+//
+// Values containing spaces, parentheses or comparison characters must be
+// double quoted.  Quoted values take the same operator prefixes and comma
+// separated lists as query values e.g. name="re:^(api|web)-" or
+// name=re:"^(api|web)-"
+func ParseExpr(in string) (Expr, error) {
+	tokens, err := lex(in)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, end: len(in)}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != nil {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: "unexpected " + tok.String()}
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+func (t *token) String() string {
+	if t.kind == tokString {
+		return fmt.Sprintf("%q", t.val)
+	}
+	return "'" + t.val + "'"
+}
+
+// keyword returns true if the token is the given case insensitive keyword
+func (t *token) keyword(kw string) bool {
+	return t != nil && t.kind == tokWord && strings.EqualFold(t.val, kw)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isOpChar(c byte) bool {
+	return c == '=' || c == '!' || c == '<' || c == '>'
+}
+
+func lex(in string) ([]*token, error) {
+	var tokens []*token
+	for i := 0; i < len(in); {
+		c := in[i]
+		switch {
+		case isSpace(c):
+			i++
+
+		case c == '(':
+			tokens = append(tokens, &token{kind: tokLParen, val: "(", pos: i})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, &token{kind: tokRParen, val: ")", pos: i})
+			i++
+
+		case c == '"':
+			start := i
+			var sb strings.Builder
+			for i++; i < len(in) && in[i] != '"'; i++ {
+				if in[i] == '\\' && i+1 < len(in) {
+					i++
+				}
+				sb.WriteByte(in[i])
+			}
+			if i >= len(in) {
+				return nil, &SyntaxError{Pos: start, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, &token{kind: tokString, val: sb.String(), pos: start})
+			i++
+
+		case isOpChar(c):
+			start := i
+			for i < len(in) && isOpChar(in[i]) {
+				i++
+			}
+			op := in[start:i]
+			if _, ok := exprOps[op]; !ok {
+				return nil, &SyntaxError{Pos: start, Msg: "unknown operator '" + op + "'"}
+			}
+			tokens = append(tokens, &token{kind: tokOp, val: op, pos: start})
+
+		default:
+			start := i
+			for i < len(in) && !isSpace(in[i]) && !isOpChar(in[i]) &&
+				in[i] != '(' && in[i] != ')' && in[i] != '"' {
+				i++
+			}
+			tokens = append(tokens, &token{kind: tokWord, val: in[start:i], pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []*token
+	i      int
+	// Length of the input used as the position of errors at the end
+	end int
+}
+
+func (p *parser) peek() *token {
+	if p.i < len(p.tokens) {
+		return p.tokens[p.i]
+	}
+	return nil
+}
+
+func (p *parser) next() *token {
+	tok := p.peek()
+	if tok != nil {
+		p.i++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok *token, format string, args ...interface{}) error {
+	pos := p.end
+	if tok != nil {
+		pos = tok.pos
+	}
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &OrExpr{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("AND") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &AndExpr{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peek().keyword("NOT") {
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.next()
+	if tok == nil {
+		return nil, p.errorf(nil, "unexpected end of expression")
+	}
+
+	switch tok.kind {
+	case tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing == nil || closing.kind != tokRParen {
+			return nil, p.errorf(closing, "missing ')' for '(' at position %d", tok.pos)
+		}
+		return expr, nil
+
+	case tokWord:
+		if tok.keyword("AND") || tok.keyword("OR") {
+			return nil, p.errorf(tok, "expected field before %s", tok)
+		}
+		return p.parseComparison(tok)
+	}
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+func (p *parser) parseComparison(field *token) (Expr, error) {
+	opTok := p.next()
+	if opTok == nil || opTok.kind != tokOp {
+		return nil, p.errorf(opTok, "expected comparison operator after field '%s'", field.val)
+	}
+
+	valTok := p.next()
+	if valTok == nil || (valTok.kind != tokWord && valTok.kind != tokString) {
+		return nil, p.errorf(valTok, "expected value after %s", opTok)
+	}
+
+	// An operator prefix may precede a quoted value e.g. name=re:"^(api|web)"
+	val := valTok.val
+	if next := p.peek(); valTok.kind == tokWord && strings.HasSuffix(val, ":") &&
+		next != nil && next.kind == tokString && next.pos == valTok.pos+len(val) {
+		val += p.next().val
+	}
+
+	var filter Filter
+	filter.Operator, filter.Values = ParseValue(val)
+
+	if op := exprOps[opTok.val]; op != NoOp {
+		if filter.Operator != NoOp {
+			return nil, p.errorf(valTok, "operator %s cannot be combined with '%s:'", opTok, filter.Operator)
+		}
+		filter.Operator = op
+	}
+	if err := filter.Compile(); err != nil {
+		return nil, p.errorf(valTok, "invalid pattern: %v", err)
+	}
+
+	return &FieldExpr{Field: field.val, Filter: filter}, nil
+}
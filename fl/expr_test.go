@@ -0,0 +1,102 @@
+package fl
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMatcher matches string fields with MatchString and the CPUShares field
+// with MatchInt64
+type testMatcher map[string]string
+
+func (m testMatcher) MatchField(name string, filters ...Filter) bool {
+	val, ok := m[name]
+	for _, filter := range filters {
+		if match, isExists := MatchExists(ok, filter); isExists {
+			if !match {
+				return false
+			}
+			continue
+		}
+		if !ok {
+			return false
+		}
+		if name == "CPUShares" {
+			i, _ := strconv.ParseInt(val, 10, 64)
+			if !MatchInt64(i, filter) {
+				return false
+			}
+		} else if !MatchString(val, filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func Test_ParseExpr(t *testing.T) {
+	expr, err := ParseExpr(`team=infra AND (service=api OR CPUShares>1024) AND NOT env=dev`)
+	assert.Nil(t, err)
+	assert.Equal(t, "((team=infra AND (service=api OR CPUShares=gt:1024)) AND NOT env=dev)", expr.String())
+
+	assert.True(t, expr.Eval(testMatcher{"team": "infra", "service": "api", "env": "prod"}))
+	assert.True(t, expr.Eval(testMatcher{"team": "infra", "service": "db", "CPUShares": "2048"}))
+	assert.False(t, expr.Eval(testMatcher{"team": "infra", "service": "db", "CPUShares": "512"}))
+	assert.False(t, expr.Eval(testMatcher{"team": "infra", "service": "api", "env": "dev"}))
+
+	// Precedence of AND over OR and operator prefixes in values
+	expr, err = ParseExpr(`name=prefix:batch- or team="data eng" and env!=dev`)
+	assert.Nil(t, err)
+	assert.True(t, expr.Eval(testMatcher{"name": "batch-1"}))
+	assert.True(t, expr.Eval(testMatcher{"team": "data eng", "env": "prod"}))
+	assert.False(t, expr.Eval(testMatcher{"team": "data eng", "env": "dev"}))
+
+	// Operator prefixes in and before quoted values
+	for _, in := range []string{`name="re:^(api|web)-"`, `name=re:"^(api|web)-"`} {
+		expr, err = ParseExpr(in)
+		assert.Nil(t, err, in)
+		assert.True(t, expr.Eval(testMatcher{"name": "web-1"}), in)
+		assert.False(t, expr.Eval(testMatcher{"name": "db-1"}), in)
+	}
+}
+
+func Test_ParseExpr_Errors(t *testing.T) {
+	for in, pos := range map[string]int{
+		"":                    0,
+		"team":                4,
+		"team=":               5,
+		"team=infra AND":      14,
+		"(team=infra":         11,
+		"team=infra)":         10,
+		"team=<infra":         4,
+		`team="infra`:         5,
+		"AND team=infra":      0,
+		"team<prefix:infra":   5,
+		"name=re:( OR a=b":    8,
+		"team=infra env=prod": 11,
+	} {
+		_, err := ParseExpr(in)
+		if !assert.NotNil(t, err, in) {
+			continue
+		}
+		serr, ok := err.(*SyntaxError)
+		if assert.True(t, ok, in) {
+			assert.Equal(t, pos, serr.Pos, in)
+		}
+	}
+}
+
+func Test_ParseQueryExpr(t *testing.T) {
+	expr, err := ParseQueryExpr(map[string][]string{
+		"team": []string{"infra"},
+		"q":    []string{"service=api OR service=web"},
+	}, nil)
+	assert.Nil(t, err)
+	assert.True(t, expr.Eval(testMatcher{"team": "infra", "service": "web"}))
+	assert.False(t, expr.Eval(testMatcher{"team": "data", "service": "web"}))
+
+	expr, err = ParseQueryExpr(map[string][]string{}, nil)
+	assert.Nil(t, err)
+	assert.True(t, expr.Eval(testMatcher{}))
+}
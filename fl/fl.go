@@ -2,6 +2,7 @@
 package fl
 
 import (
+	"regexp"
 	"strings"
 )
 
@@ -13,6 +14,10 @@ const ListDelimiter = ","
 // an Op if it contains one and parses the or's returning the array
 func ParseValue(in string) (string, []string) {
 	op, field := parseOp(in)
+	if op == OpRegex {
+		// Regular expressions may contain the delimiter
+		return op, []string{field}
+	}
 	return op, parseDelimited(field, ListDelimiter)
 }
 
@@ -31,27 +36,90 @@ func parseDelimited(in, delimiter string) []string {
 type Filter struct {
 	Operator string
 	Values   []string
+
+	// Compiled regex and glob values.  Nil until compiled
+	patterns []*regexp.Regexp
+}
+
+// Compile compiles regex and glob values so they are not compiled on each
+// match.  Valid values are kept if others fail to compile and the first
+// error is returned.  It is a no-op for other operators
+func (f *Filter) Compile() error {
+	if f.Operator != OpRegex && f.Operator != OpGlob {
+		return nil
+	}
+
+	var (
+		patterns = make([]*regexp.Regexp, 0, len(f.Values))
+		first    error
+	)
+	for _, val := range f.Values {
+		expr := val
+		if f.Operator == OpGlob {
+			expr = globToRegex(val)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	f.patterns = patterns
+	return first
+}
+
+// Patterns returns the compiled regex or glob values compiling them once if
+// needed.  Invalid patterns are skipped
+func (f *Filter) Patterns() []*regexp.Regexp {
+	if f.patterns == nil {
+		f.Compile()
+	}
+	return f.patterns
+}
+
+// globToRegex converts a shell glob to an anchored regular expression
+func globToRegex(glob string) string {
+	expr := regexp.QuoteMeta(glob)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+	return "^" + expr + "$"
 }
 
 // Query holds the complet query request
 type Query map[string][]Filter
 
-// ParseQuery ...
-func ParseQuery(in map[string][]string) Query {
+// ParseQuery parses query parameters into filters by field.  If schema is
+// not nil the filters are validated against it and a ValidationError is
+// returned listing each invalid one.  Without a schema the error of the
+// first pattern that fails to compile is returned along with the query in
+// which invalid patterns never match
+func ParseQuery(in map[string][]string, schema *Schema) (Query, error) {
 	queries := make(map[string][]Filter)
 	for k := range in {
 		queries[k] = make([]Filter, 0)
 	}
 
+	var compileErr error
 	for k, vals := range in {
 		for _, val := range vals {
 			var q Filter
 			q.Operator, q.Values = ParseValue(val)
+			if err := q.Compile(); err != nil && compileErr == nil {
+				compileErr = err
+			}
 
 			t := queries[k]
 			queries[k] = append(t, q)
 		}
 	}
 
-	return queries
+	if schema != nil {
+		if err := schema.Validate(queries); err != nil {
+			return nil, err
+		}
+	}
+	return queries, compileErr
 }
@@ -25,6 +25,15 @@ var testOps = []testOp{
 	testOp{in: "lt:foo", eField: "foo", eOp: OpLess},
 	testOp{in: "le:foo", eField: "foo", eOp: OpLessEqual},
 	testOp{in: "ne:foo", eField: "foo", eOp: OpNotEqual},
+	testOp{in: "re:^foo$", eField: "^foo$", eOp: OpRegex},
+	testOp{in: "prefix:foo", eField: "foo", eOp: OpPrefix},
+	testOp{in: "suffix:foo", eField: "foo", eOp: OpSuffix},
+	testOp{in: "glob:fo*", eField: "fo*", eOp: OpGlob},
+	testOp{in: "ieq:FOO", eField: "FOO", eOp: OpEqualFold},
+	testOp{in: "exists:", eField: "", eOp: OpExists},
+	testOp{in: "missing:", eField: "", eOp: OpMissing},
+	testOp{in: "ab", eField: "ab", eOp: NoOp},
+	testOp{in: "2019-02-19T00:00:00Z", eField: "2019-02-19T00:00:00Z", eOp: NoOp},
 }
 
 func Test_parseDelimited(t *testing.T) {
@@ -47,3 +56,44 @@ func Test_ParseValue(t *testing.T) {
 		assert.Equal(t, top.eField, strings.Join(fields, ListDelimiter))
 	}
 }
+
+func Test_MatchString(t *testing.T) {
+	q, _ := ParseQuery(map[string][]string{
+		"re":     []string{"re:^batch-[0-9]{1,3}$"},
+		"prefix": []string{"prefix:batch-,cron-"},
+		"suffix": []string{"suffix:-worker"},
+		"glob":   []string{"glob:batch-*-w?rker"},
+		"ieq":    []string{"ieq:Batch-1"},
+	}, nil)
+	assert.NotNil(t, q["re"][0].patterns)
+	assert.Equal(t, 1, len(q["re"][0].Values))
+
+	assert.True(t, MatchString("batch-12", q["re"][0]))
+	assert.False(t, MatchString("batch-1234", q["re"][0]))
+	assert.True(t, MatchString("cron-1", q["prefix"][0]))
+	assert.False(t, MatchString("api", q["prefix"][0]))
+	assert.True(t, MatchString("batch-worker", q["suffix"][0]))
+	assert.True(t, MatchString("batch-1-worker", q["glob"][0]))
+	assert.False(t, MatchString("batch-1-workers", q["glob"][0]))
+	assert.True(t, MatchString("BATCH-1", q["ieq"][0]))
+
+	// Uncompiled filters compile on match
+	assert.True(t, MatchString("batch-1", Filter{Operator: OpGlob, Values: []string{"batch-*"}}))
+	// Invalid patterns never match and are compiled once
+	invalid := Filter{Operator: OpRegex, Values: []string{"(", "^batch-"}}
+	assert.True(t, MatchString("batch-1", invalid))
+	assert.Equal(t, 1, len(invalid.Patterns()))
+	assert.NotNil(t, invalid.patterns)
+	assert.False(t, MatchString("batch-1", Filter{Operator: OpRegex, Values: []string{"("}}))
+
+	// Compile errors are returned without a schema
+	q, err := ParseQuery(map[string][]string{"re": []string{"re:("}}, nil)
+	assert.NotNil(t, err)
+	assert.False(t, MatchString("(", q["re"][0]))
+
+	match, ok := MatchExists(false, Filter{Operator: OpMissing})
+	assert.True(t, ok)
+	assert.True(t, match)
+	_, ok = MatchExists(true, Filter{Operator: NoOp})
+	assert.False(t, ok)
+}
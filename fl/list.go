@@ -0,0 +1,292 @@
+package fl
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// List query parameters.  These are removed from the parameters by
+// ParseListOptions so the remainder can be parsed as a query
+const (
+	// Comma separated fields with an optional :asc or :desc suffix e.g.
+	// sort=UnitsBurned:desc,Name
+	SortParam = "sort"
+	// Maximum number of items in a page
+	LimitParam = "limit"
+	// Opaque cursor returned with the previous page
+	CursorParam = "cursor"
+	// Comma separated top level fields to include in each item
+	FieldsParam = "fields"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// Valuer is implemented by anything that can be sorted on named fields.
+// FieldValue returns a string, int64 or float64 matching the schema type of
+// the field, or nil if it is not set.  Times are epoch nano and durations
+// nanoseconds
+type Valuer interface {
+	FieldValue(name string) interface{}
+}
+
+// SortKey is a single field to sort on
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions holds the sorting, pagination and projection of a listing
+type ListOptions struct {
+	Sort []SortKey
+	// Zero means no limit
+	Limit  int
+	Fields []string
+
+	schema *Schema
+	// Sort values of the last item of the previous page including the key
+	after []interface{}
+}
+
+// ParseListOptions removes the list parameters from params and parses them.
+// Sort fields are validated against the schema.  The schema key is always
+// the final sort field so pages are stable across inserts
+func ParseListOptions(params map[string][]string, schema *Schema) (ListOptions, error) {
+	opts := ListOptions{schema: schema}
+	var errs []FieldError
+
+	for _, val := range params[SortParam] {
+		for _, field := range parseDelimited(val, ListDelimiter) {
+			key := SortKey{Field: field}
+			if i := strings.LastIndexByte(field, ':'); i > 0 {
+				switch field[i+1:] {
+				case "desc":
+					key = SortKey{Field: field[:i], Desc: true}
+				case "asc":
+					key = SortKey{Field: field[:i]}
+				}
+			}
+			if _, _, ok := schema.Field(key.Field); !ok {
+				errs = append(errs, FieldError{Field: key.Field, Kind: ErrKindUnknownField, Msg: "cannot sort on unknown field"})
+				continue
+			}
+			opts.Sort = append(opts.Sort, key)
+		}
+	}
+	if schema.Key != "" {
+		opts.Sort = append(opts.Sort, SortKey{Field: schema.Key})
+	}
+
+	if val := lastParam(params, LimitParam); val != "" {
+		limit, err := strconv.Atoi(val)
+		if err != nil || limit < 0 {
+			errs = append(errs, badLiteral(LimitParam, NoOp, val, "not a valid limit"))
+		}
+		opts.Limit = limit
+	}
+
+	if val := lastParam(params, CursorParam); val != "" {
+		after, err := opts.decodeCursor(val)
+		if err != nil {
+			errs = append(errs, badLiteral(CursorParam, NoOp, val, "not a valid cursor for the sort order"))
+		}
+		opts.after = after
+	}
+
+	for _, val := range params[FieldsParam] {
+		opts.Fields = append(opts.Fields, parseDelimited(val, ListDelimiter)...)
+	}
+
+	for _, p := range []string{SortParam, LimitParam, CursorParam, FieldsParam} {
+		delete(params, p)
+	}
+
+	if len(errs) > 0 {
+		return opts, &ValidationError{Errors: errs}
+	}
+	return opts, nil
+}
+
+func lastParam(params map[string][]string, name string) string {
+	if vals := params[name]; len(vals) > 0 {
+		return vals[len(vals)-1]
+	}
+	return ""
+}
+
+func (opts *ListOptions) values(item Valuer) []interface{} {
+	vals := make([]interface{}, len(opts.Sort))
+	for i, key := range opts.Sort {
+		vals[i] = item.FieldValue(key.Field)
+	}
+	return vals
+}
+
+// compare compares the sort values of two items in the sort order
+func (opts *ListOptions) compare(a, b []interface{}) int {
+	for i, key := range opts.Sort {
+		c := compareValues(a[i], b[i])
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareValues orders values of the same type. Unset values sort first
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+
+	switch av := a.(type) {
+	case string:
+		return strings.Compare(av, b.(string))
+	case int64:
+		bv := b.(int64)
+		if av < bv {
+			return -1
+		} else if av > bv {
+			return 1
+		}
+	case float64:
+		bv := b.(float64)
+		if av < bv {
+			return -1
+		} else if av > bv {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Page sorts the items and returns those after the cursor up to the limit
+// along with the cursor of the next page.  The cursor is empty on the last
+// page
+func (opts *ListOptions) Page(items []Valuer) ([]Valuer, string) {
+	vals := make([][]interface{}, len(items))
+	for i, item := range items {
+		vals[i] = opts.values(item)
+	}
+	idx := make([]int, len(items))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return opts.compare(vals[idx[i]], vals[idx[j]]) < 0
+	})
+
+	start := 0
+	if opts.after != nil {
+		start = sort.Search(len(idx), func(i int) bool {
+			return opts.compare(vals[idx[i]], opts.after) > 0
+		})
+	}
+	end := len(idx)
+	if opts.Limit > 0 && start+opts.Limit < end {
+		end = start + opts.Limit
+	}
+
+	page := make([]Valuer, 0, end-start)
+	for _, i := range idx[start:end] {
+		page = append(page, items[i])
+	}
+
+	var next string
+	if end < len(idx) && end > start {
+		next = opts.encodeCursor(vals[idx[end-1]])
+	}
+	return page, next
+}
+
+// Project returns the items marshalled with only the requested fields.  The
+// items are returned as is if no fields were requested
+func (opts *ListOptions) Project(items []Valuer) ([]interface{}, error) {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		if len(opts.Fields) == 0 {
+			out[i] = item
+			continue
+		}
+
+		b, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		if err = json.Unmarshal(b, &all); err != nil {
+			return nil, err
+		}
+		proj := make(map[string]json.RawMessage, len(opts.Fields))
+		for _, field := range opts.Fields {
+			if v, ok := all[field]; ok {
+				proj[field] = v
+			}
+		}
+		out[i] = proj
+	}
+	return out, nil
+}
+
+// encodeCursor encodes the sort values as strings so int64 values survive
+// the round trip
+func (opts *ListOptions) encodeCursor(vals []interface{}) string {
+	strs := make([]*string, len(vals))
+	for i, v := range vals {
+		if v != nil {
+			s := formatValue(v)
+			strs[i] = &s
+		}
+	}
+	b, _ := json.Marshal(strs)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (opts *ListOptions) decodeCursor(cursor string) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var strs []*string
+	if err = json.Unmarshal(b, &strs); err != nil {
+		return nil, err
+	}
+	if len(strs) != len(opts.Sort) {
+		return nil, errInvalidCursor
+	}
+
+	vals := make([]interface{}, len(strs))
+	for i, s := range strs {
+		if s == nil {
+			continue
+		}
+		ft, dynamic, _ := opts.schema.Field(opts.Sort[i].Field)
+		if dynamic {
+			ft = FieldString
+		}
+		switch ft {
+		case FieldString:
+			vals[i] = *s
+		case FieldFloat:
+			vals[i], err = strconv.ParseFloat(*s, 64)
+		default:
+			vals[i], err = strconv.ParseInt(*s, 10, 64)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
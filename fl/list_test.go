@@ -0,0 +1,103 @@
+package fl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testItem struct {
+	Name      string
+	CPUShares int64
+	team      string
+}
+
+func (ti *testItem) FieldValue(name string) interface{} {
+	switch name {
+	case "Name":
+		return ti.Name
+	case "CPUShares":
+		return ti.CPUShares
+	case "team":
+		if ti.team == "" {
+			return nil
+		}
+		return ti.team
+	}
+	return nil
+}
+
+var testListSchema = &Schema{
+	Fields:  map[string]FieldType{"Name": FieldString, "CPUShares": FieldInt},
+	Dynamic: DynamicLower,
+	Key:     "Name",
+}
+
+func testItems() []Valuer {
+	return []Valuer{
+		&testItem{"c", 512, "infra"},
+		&testItem{"a", 1024, ""},
+		&testItem{"d", 1024, "data"},
+		&testItem{"b", 256, "infra"},
+	}
+}
+
+func names(items []Valuer) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = item.(*testItem).Name
+	}
+	return out
+}
+
+func Test_ListOptions_Page(t *testing.T) {
+	params := map[string][]string{
+		"sort":  []string{"CPUShares:desc"},
+		"limit": []string{"2"},
+		"team":  []string{"infra"},
+	}
+	opts, err := ParseListOptions(params, testListSchema)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{"team": []string{"infra"}}, params)
+
+	page, next := opts.Page(testItems())
+	assert.Equal(t, []string{"a", "d"}, names(page))
+	assert.NotEqual(t, "", next)
+
+	// Inserting before the cursor does not shift the next page
+	items := append(testItems(), &testItem{"0", 2048, ""})
+	opts, err = ParseListOptions(map[string][]string{
+		"sort":   []string{"CPUShares:desc"},
+		"limit":  []string{"2"},
+		"cursor": []string{next},
+	}, testListSchema)
+	assert.Nil(t, err)
+	page, next = opts.Page(items)
+	assert.Equal(t, []string{"c", "b"}, names(page))
+	assert.Equal(t, "", next)
+
+	// Unset values sort first and the key breaks ties
+	opts, _ = ParseListOptions(map[string][]string{"sort": []string{"team"}}, testListSchema)
+	page, _ = opts.Page(testItems())
+	assert.Equal(t, []string{"a", "d", "b", "c"}, names(page))
+}
+
+func Test_ParseListOptions_Errors(t *testing.T) {
+	_, err := ParseListOptions(map[string][]string{
+		"sort":   []string{"Nmae:desc"},
+		"limit":  []string{"-1"},
+		"cursor": []string{"!!"},
+	}, testListSchema)
+	verr, ok := err.(*ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(verr.Errors))
+}
+
+func Test_ListOptions_Project(t *testing.T) {
+	opts, _ := ParseListOptions(map[string][]string{"fields": []string{"Name"}}, testListSchema)
+	out, err := opts.Project(testItems()[:1])
+	assert.Nil(t, err)
+	b, _ := json.Marshal(out)
+	assert.Equal(t, `[{"Name":"c"}]`, string(b))
+}
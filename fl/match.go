@@ -1,8 +1,8 @@
 package fl
 
 import (
-	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -140,7 +140,10 @@ func MatchInt64(value int64, filter Filter) bool {
 	return false
 }
 
+// MatchTime returns true if the time matches the filter.  Filter values may
+// be absolute RFC3339 times or relative times as accepted by ParseTime
 func MatchTime(inTime time.Time, filter Filter) bool {
+	now := time.Now()
 	switch filter.Operator {
 	case NoOp:
 		rfc3339 := inTime.Format(time.RFC3339)
@@ -148,11 +151,18 @@ func MatchTime(inTime time.Time, filter Filter) bool {
 			if rfc3339 == val {
 				return true
 			}
+			if _, err := time.Parse(time.RFC3339, val); err == nil {
+				continue
+			}
+			// Relative times are matched to the second
+			if t, err := ParseTime(val, now); err == nil && inTime.Unix() == t.Unix() {
+				return true
+			}
 		}
 		return false
 	case OpLess:
 		for _, val := range filter.Values {
-			if t, err := time.Parse(time.RFC3339, val); err == nil {
+			if t, err := ParseTime(val, now); err == nil {
 				if inTime.UnixNano() < t.UnixNano() {
 					return true
 				}
@@ -161,8 +171,7 @@ func MatchTime(inTime time.Time, filter Filter) bool {
 		return false
 	case OpLessEqual:
 		for _, val := range filter.Values {
-			if t, err := time.Parse(time.RFC3339, val); err == nil {
-				fmt.Println(inTime.UnixNano(), t.UnixNano())
+			if t, err := ParseTime(val, now); err == nil {
 				if inTime.UnixNano() <= t.UnixNano() {
 					return true
 				}
@@ -171,7 +180,7 @@ func MatchTime(inTime time.Time, filter Filter) bool {
 		return false
 	case OpGreater:
 		for _, val := range filter.Values {
-			if t, err := time.Parse(time.RFC3339, val); err == nil {
+			if t, err := ParseTime(val, now); err == nil {
 				if inTime.UnixNano() > t.UnixNano() {
 					return true
 				}
@@ -180,7 +189,7 @@ func MatchTime(inTime time.Time, filter Filter) bool {
 		return false
 	case OpGreaterEqual:
 		for _, val := range filter.Values {
-			if t, err := time.Parse(time.RFC3339, val); err == nil {
+			if t, err := ParseTime(val, now); err == nil {
 				if inTime.UnixNano() >= t.UnixNano() {
 					return true
 				}
@@ -192,6 +201,37 @@ func MatchTime(inTime time.Time, filter Filter) bool {
 	return false
 }
 
+// MatchDuration returns true if the duration matches the filter.  Filter
+// values are durations as accepted by ParseDuration
+func MatchDuration(dur time.Duration, filter Filter) bool {
+	for _, val := range filter.Values {
+		d, err := ParseDuration(val)
+		if err != nil {
+			continue
+		}
+
+		var match bool
+		switch filter.Operator {
+		case NoOp:
+			match = dur == d
+		case OpNotEqual:
+			match = dur != d
+		case OpLess:
+			match = dur < d
+		case OpLessEqual:
+			match = dur <= d
+		case OpGreater:
+			match = dur > d
+		case OpGreaterEqual:
+			match = dur >= d
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
 func MatchString(val string, filter Filter) bool {
 	switch filter.Operator {
 	case NoOp:
@@ -211,8 +251,57 @@ func MatchString(val string, filter Filter) bool {
 			}
 		}
 		return true
+
+	case OpEqualFold:
+		for _, fval := range filter.Values {
+			if strings.EqualFold(fval, val) {
+				return true
+			}
+		}
+		return false
+
+	case OpPrefix:
+		for _, fval := range filter.Values {
+			if strings.HasPrefix(val, fval) {
+				return true
+			}
+		}
+		return false
+
+	case OpSuffix:
+		for _, fval := range filter.Values {
+			if strings.HasSuffix(val, fval) {
+				return true
+			}
+		}
+		return false
+
+	case OpRegex, OpGlob:
+		for _, re := range filter.Patterns() {
+			if re.MatchString(val) {
+				return true
+			}
+		}
+		return false
+
+	case OpExists:
+		// The caller only has a value if it exists
+		return true
 	}
 
 	// Default
 	return false
 }
+
+// MatchExists returns true if the filter is an existence check and it
+// matches whether the key is set.  The second value is false if the filter
+// is not an existence check
+func MatchExists(ok bool, filter Filter) (bool, bool) {
+	switch filter.Operator {
+	case OpExists:
+		return ok, true
+	case OpMissing:
+		return !ok, true
+	}
+	return false, false
+}
@@ -1,5 +1,7 @@
 package fl
 
+import "strings"
+
 const (
 	// NoOp represents no operator
 	NoOp = ""
@@ -18,17 +20,40 @@ const (
 	OpGreaterEqual = "ge"
 )
 
+const (
+	// OpRegex matches values against a regular expression
+	OpRegex = "re"
+	// OpPrefix matches values starting with the given prefix
+	OpPrefix = "prefix"
+	// OpSuffix matches values ending with the given suffix
+	OpSuffix = "suffix"
+	// OpGlob matches values against a shell glob where * matches any
+	// sequence and ? any single character
+	OpGlob = "glob"
+	// OpEqualFold matches values equal under case folding
+	OpEqualFold = "ieq"
+	// OpExists matches if the label or meta key is set. It takes no values
+	OpExists = "exists"
+	// OpMissing matches if the label or meta key is not set. It takes no
+	// values
+	OpMissing = "missing"
+)
+
 // parseOp parses the input string checking if it contains any operators
 // It returns the Op and remainder value or a NoOp and the input string.
 func parseOp(in string) (string, string) {
-	if in[2] == ':' {
-		op := in[:2]
-		switch op {
-		case OpNotEqual, OpLess, OpGreater,
-			OpLessEqual, OpGreaterEqual:
-			return op, in[3:]
-		}
+	i := strings.IndexByte(in, ':')
+	if i < 0 {
+		return NoOp, in
+	}
 
+	op := in[:i]
+	switch op {
+	case OpNotEqual, OpLess, OpGreater,
+		OpLessEqual, OpGreaterEqual,
+		OpRegex, OpPrefix, OpSuffix, OpGlob, OpEqualFold,
+		OpExists, OpMissing:
+		return op, in[i+1:]
 	}
 	return NoOp, in
 }
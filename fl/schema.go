@@ -0,0 +1,304 @@
+package fl
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// FieldType is the type of a queryable field
+type FieldType int
+
+const (
+	// FieldString is matched with MatchString
+	FieldString FieldType = iota
+	// FieldInt is matched with MatchInt64
+	FieldInt
+	// FieldFloat is matched with MatchFloat64
+	FieldFloat
+	// FieldTime is matched with MatchTime
+	FieldTime
+	// FieldDuration is matched with MatchDuration
+	FieldDuration
+)
+
+func (ft FieldType) String() string {
+	switch ft {
+	case FieldInt:
+		return "int"
+	case FieldFloat:
+		return "float"
+	case FieldTime:
+		return "time"
+	case FieldDuration:
+		return "duration"
+	}
+	return "string"
+}
+
+// DynamicKeys declares which names not in a schema are treated as dynamic
+// keys e.g. container labels or node meta
+type DynamicKeys int
+
+const (
+	// DynamicNone only allows dynamic keys with the schema prefix
+	DynamicNone DynamicKeys = iota
+	// DynamicLower also allows names starting with a lower case letter.
+	// Names starting with an upper case letter are assumed to be misspelled
+	// fields
+	DynamicLower
+	// DynamicAny also allows any name
+	DynamicAny
+)
+
+// Schema describes the queryable fields of a type
+type Schema struct {
+	Fields map[string]FieldType
+	// Prefix explicitly addressing a dynamic key e.g. Labels.
+	DynamicPrefix string
+	// Bare names allowed as dynamic keys
+	Dynamic DynamicKeys
+	// Field uniquely identifying an item.  It breaks ties when sorting
+	Key string
+}
+
+var (
+	schemaMu sync.RWMutex
+	schemas  = make(map[string]*Schema)
+)
+
+// RegisterSchema registers the schema of a queryable type by name
+func RegisterSchema(name string, schema *Schema) {
+	schemaMu.Lock()
+	schemas[name] = schema
+	schemaMu.Unlock()
+}
+
+// SchemaFor returns the registered schema by name or nil
+func SchemaFor(name string) *Schema {
+	schemaMu.RLock()
+	defer schemaMu.RUnlock()
+	return schemas[name]
+}
+
+// Validation error kinds
+const (
+	ErrKindUnknownField = "unknown field"
+	ErrKindBadOperator  = "bad operator"
+	ErrKindBadLiteral   = "bad literal"
+)
+
+// FieldError describes a single invalid field filter
+type FieldError struct {
+	Field    string
+	Kind     string
+	Operator string `json:",omitempty"`
+	Value    string `json:",omitempty"`
+	Msg      string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Kind + ": " + e.Msg
+}
+
+// ValidationError holds all field errors of a query
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid query: " + strings.Join(msgs, "; ")
+}
+
+// operators allowed by field type.  Dynamic keys are strings that may also
+// be checked for existence
+var (
+	stringOps  = []string{NoOp, OpNotEqual, OpRegex, OpPrefix, OpSuffix, OpGlob, OpEqualFold}
+	numericOps = []string{NoOp, OpNotEqual, OpLess, OpLessEqual, OpGreater, OpGreaterEqual}
+	timeOps    = []string{NoOp, OpLess, OpLessEqual, OpGreater, OpGreaterEqual}
+	dynamicOps = append([]string{OpExists, OpMissing}, stringOps...)
+)
+
+// Field returns the type of the named field and whether it is a dynamic key.
+// ok is false if the name is not allowed by the schema
+func (s *Schema) Field(name string) (ft FieldType, dynamic bool, ok bool) {
+	if ft, ok = s.Fields[name]; ok {
+		return ft, false, true
+	}
+	if s.DynamicPrefix != "" && strings.HasPrefix(name, s.DynamicPrefix) {
+		return FieldString, true, len(name) > len(s.DynamicPrefix)
+	}
+
+	// A case mismatch of a known field is assumed to be a typo
+	for field := range s.Fields {
+		if strings.EqualFold(field, name) {
+			return FieldString, false, false
+		}
+	}
+
+	switch s.Dynamic {
+	case DynamicAny:
+		return FieldString, true, name != ""
+	case DynamicLower:
+		return FieldString, true, name != "" && !unicode.IsUpper(rune(name[0]))
+	}
+	return FieldString, false, false
+}
+
+// suggest returns a known field equal under case folding to name if any
+func (s *Schema) suggest(name string) string {
+	for field := range s.Fields {
+		if strings.EqualFold(field, name) {
+			return field
+		}
+	}
+	return ""
+}
+
+// ValidateFilter returns the errors of a single filter on the named field
+func (s *Schema) ValidateFilter(name string, filter Filter) []FieldError {
+	ft, dynamic, ok := s.Field(name)
+	if !ok {
+		msg := "not a field"
+		if s.DynamicPrefix != "" {
+			msg += " or " + s.DynamicPrefix + "<key>"
+		}
+		if sug := s.suggest(name); sug != "" {
+			msg += ", did you mean " + sug
+		}
+		return []FieldError{{Field: name, Kind: ErrKindUnknownField, Msg: msg}}
+	}
+
+	allowed := stringOps
+	if dynamic {
+		allowed = dynamicOps
+	} else {
+		switch ft {
+		case FieldInt, FieldFloat, FieldDuration:
+			allowed = numericOps
+		case FieldTime:
+			allowed = timeOps
+		}
+	}
+	if !containsOp(allowed, filter.Operator) {
+		return []FieldError{{
+			Field:    name,
+			Kind:     ErrKindBadOperator,
+			Operator: filter.Operator,
+			Msg:      "operator '" + filter.Operator + "' not supported for " + ft.String(),
+		}}
+	}
+
+	var errs []FieldError
+	switch filter.Operator {
+	case OpExists, OpMissing:
+		return nil
+	case OpRegex:
+		for _, val := range filter.Values {
+			if _, err := regexp.Compile(val); err != nil {
+				errs = append(errs, badLiteral(name, filter.Operator, val, err.Error()))
+			}
+		}
+		return errs
+	}
+
+	if len(filter.Values) == 0 {
+		return []FieldError{badLiteral(name, filter.Operator, "", "missing value")}
+	}
+
+	now := time.Now()
+	for _, val := range filter.Values {
+		var err error
+		switch ft {
+		case FieldInt:
+			_, err = strconv.ParseInt(val, 10, 64)
+		case FieldFloat:
+			_, err = strconv.ParseFloat(val, 64)
+		case FieldTime:
+			_, err = ParseTime(val, now)
+		case FieldDuration:
+			_, err = ParseDuration(val)
+		}
+		if err != nil {
+			errs = append(errs, badLiteral(name, filter.Operator, val, "not a valid "+ft.String()))
+		}
+	}
+	return errs
+}
+
+func badLiteral(field, op, val, msg string) FieldError {
+	return FieldError{Field: field, Kind: ErrKindBadLiteral, Operator: op, Value: val, Msg: msg}
+}
+
+func containsOp(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns a ValidationError with all invalid filters of the query
+// or nil.  Fields are validated in sorted order
+func (s *Schema) Validate(query Query) error {
+	return s.ValidateExpr(query.Expr())
+}
+
+// ValidateExpr returns a ValidationError with all invalid field filters in
+// the expression or nil
+func (s *Schema) ValidateExpr(expr Expr) error {
+	var errs []FieldError
+	walkFields(expr, func(fe *FieldExpr) {
+		errs = append(errs, s.ValidateFilter(fe.Field, fe.Filter)...)
+	})
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func walkFields(expr Expr, f func(*FieldExpr)) {
+	switch e := expr.(type) {
+	case *AndExpr:
+		walkFields(e.Left, f)
+		walkFields(e.Right, f)
+	case *OrExpr:
+		walkFields(e.Left, f)
+		walkFields(e.Right, f)
+	case *NotExpr:
+		walkFields(e.Expr, f)
+	case *FieldExpr:
+		f(e)
+	}
+}
+
+// ErrorResponse is the body returned for a query that fails to parse or
+// validate
+type ErrorResponse struct {
+	Error string
+	// Position of a syntax error in the expression
+	Pos    *int         `json:",omitempty"`
+	Errors []FieldError `json:",omitempty"`
+}
+
+// NewErrorResponse returns the response for a query error including the
+// details of syntax and validation errors
+func NewErrorResponse(err error) ErrorResponse {
+	resp := ErrorResponse{Error: err.Error()}
+	switch e := err.(type) {
+	case *SyntaxError:
+		pos := e.Pos
+		resp.Pos = &pos
+	case *ValidationError:
+		resp.Errors = e.Errors
+	}
+	return resp
+}
@@ -0,0 +1,81 @@
+package fl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSchema = &Schema{
+	Fields: map[string]FieldType{
+		"Name":      FieldString,
+		"Create":    FieldTime,
+		"CPUShares": FieldInt,
+		"RunTime":   FieldDuration,
+	},
+	DynamicPrefix: "Labels.",
+	Dynamic:       DynamicLower,
+}
+
+func Test_ParseQuery_Schema(t *testing.T) {
+	_, err := ParseQuery(map[string][]string{
+		"Name":         []string{"prefix:api"},
+		"Create":       []string{"gt:now-24h"},
+		"CPUShares":    []string{"ge:1024"},
+		"RunTime":      []string{"lt:2h"},
+		"service":      []string{"api"},
+		"Labels.Team":  []string{"exists:"},
+		"Labels.stage": []string{"re:^prod"},
+	}, testSchema)
+	assert.Nil(t, err)
+
+	_, err = ParseQuery(map[string][]string{
+		"cpushares": []string{"1024"},
+		"Runtime":   []string{"1h"},
+		"CPUShares": []string{"gt:lots", "re:^1"},
+		"Create":    []string{"ne:now"},
+		"Name":      []string{"exists:"},
+		"RunTime":   []string{"lt:2x"},
+		"Labels.":   []string{"x"},
+	}, testSchema)
+	verr, ok := err.(*ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, 8, len(verr.Errors))
+
+	kinds := make(map[string]string)
+	for _, fe := range verr.Errors {
+		kinds[fe.Field+"="+fe.Operator] = fe.Kind
+	}
+	assert.Equal(t, ErrKindBadOperator, kinds["Create=ne"])
+	assert.Equal(t, ErrKindBadOperator, kinds["CPUShares=re"])
+	assert.Equal(t, ErrKindBadOperator, kinds["Name=exists"])
+	assert.Equal(t, ErrKindBadLiteral, kinds["CPUShares=gt"])
+	assert.Equal(t, ErrKindBadLiteral, kinds["RunTime=lt"])
+	assert.Equal(t, ErrKindUnknownField, kinds["cpushares="])
+	assert.Equal(t, ErrKindUnknownField, kinds["Runtime="])
+	assert.Equal(t, ErrKindUnknownField, kinds["Labels.="])
+	assert.Contains(t, err.Error(), "did you mean CPUShares")
+}
+
+func Test_ParseQueryExpr_Schema(t *testing.T) {
+	_, err := ParseQueryExpr(map[string][]string{
+		"q": []string{`service=api OR (CPUShares>1024 AND NOT Name=re:^batch)`},
+	}, testSchema)
+	assert.Nil(t, err)
+
+	_, err = ParseQueryExpr(map[string][]string{
+		"q": []string{`service=api OR NOT CPUShares>big`},
+	}, testSchema)
+	verr, ok := err.(*ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(verr.Errors))
+	assert.Equal(t, "big", verr.Errors[0].Value)
+
+	resp := NewErrorResponse(err)
+	assert.Equal(t, verr.Errors, resp.Errors)
+	assert.Nil(t, resp.Pos)
+
+	_, err = ParseQueryExpr(map[string][]string{"q": []string{"service="}}, testSchema)
+	resp = NewErrorResponse(err)
+	assert.NotNil(t, resp.Pos)
+}
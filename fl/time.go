@@ -0,0 +1,107 @@
+package fl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Relative time keywords.  Each may be followed by an offset e.g. now-24h or
+// today+9h
+const (
+	TimeNow          = "now"
+	TimeToday        = "today"
+	TimeYesterday    = "yesterday"
+	TimeStartOfWeek  = "startOfWeek"
+	TimeStartOfMonth = "startOfMonth"
+	TimeStartOfYear  = "startOfYear"
+)
+
+// ParseTime parses an absolute RFC3339 time or a relative time keyword with
+// an optional duration offset.  Relative times are computed from now in its
+// location.  Weeks start on Monday.
+func ParseTime(in string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, in); err == nil {
+		return t, nil
+	}
+
+	base, offset := in, ""
+	if i := strings.IndexAny(in, "+-"); i > 0 {
+		base, offset = in[:i], in[i:]
+	}
+
+	var (
+		y, m, d = now.Date()
+		loc     = now.Location()
+		t       time.Time
+	)
+	switch base {
+	case TimeNow:
+		t = now
+	case TimeToday:
+		t = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case TimeYesterday:
+		t = time.Date(y, m, d-1, 0, 0, 0, 0, loc)
+	case TimeStartOfWeek:
+		// Days since monday
+		days := (int(now.Weekday()) + 6) % 7
+		t = time.Date(y, m, d-days, 0, 0, 0, 0, loc)
+	case TimeStartOfMonth:
+		t = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case TimeStartOfYear:
+		t = time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Time{}, errors.New("invalid time: " + in)
+	}
+
+	if offset == "" {
+		return t, nil
+	}
+	dur, err := ParseDuration(offset[1:])
+	if err != nil {
+		return time.Time{}, errors.New("invalid time offset: " + in)
+	}
+	if offset[0] == '-' {
+		dur = -dur
+	}
+	return t.Add(dur), nil
+}
+
+// ParseDuration parses a duration as time.ParseDuration does with the
+// addition of d for days and w for weeks e.g. 7d or 1w12h.  Days are always
+// 24 hours.
+func ParseDuration(in string) (time.Duration, error) {
+	if in == "" {
+		return 0, errors.New("invalid duration: " + in)
+	}
+
+	var (
+		total time.Duration
+		rest  = in
+	)
+	for _, unit := range []struct {
+		suffix string
+		dur    time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		i := strings.Index(rest, unit.suffix)
+		if i < 0 {
+			continue
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, errors.New("invalid duration: " + in)
+		}
+		total += time.Duration(n * float64(unit.dur))
+		rest = rest[i+1:]
+	}
+
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, errors.New("invalid duration: " + in)
+		}
+		total += d
+	}
+	return total, nil
+}
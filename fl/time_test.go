@@ -0,0 +1,57 @@
+package fl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseTime(t *testing.T) {
+	// A wednesday
+	now, _ := time.Parse(time.RFC3339, "2019-02-20T15:04:05Z")
+
+	for in, expected := range map[string]string{
+		"2019-01-01T00:00:00Z": "2019-01-01T00:00:00Z",
+		"now":                  "2019-02-20T15:04:05Z",
+		"now-24h":              "2019-02-19T15:04:05Z",
+		"now+1h30m":            "2019-02-20T16:34:05Z",
+		"now-7d":               "2019-02-13T15:04:05Z",
+		"today":                "2019-02-20T00:00:00Z",
+		"today+9h":             "2019-02-20T09:00:00Z",
+		"yesterday":            "2019-02-19T00:00:00Z",
+		"startOfWeek":          "2019-02-18T00:00:00Z",
+		"startOfMonth":         "2019-02-01T00:00:00Z",
+		"startOfMonth-1d":      "2019-01-31T00:00:00Z",
+		"startOfYear":          "2019-01-01T00:00:00Z",
+	} {
+		tm, err := ParseTime(in, now)
+		assert.Nil(t, err, in)
+		assert.Equal(t, expected, tm.Format(time.RFC3339), in)
+	}
+
+	for _, in := range []string{"", "later", "now-", "now-xyz", "today-1x"} {
+		_, err := ParseTime(in, now)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func Test_ParseDuration(t *testing.T) {
+	d, err := ParseDuration("1w2d3h")
+	assert.Nil(t, err)
+	assert.Equal(t, 9*24*time.Hour+3*time.Hour, d)
+
+	d, err = ParseDuration("5m")
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Minute, d)
+
+	_, err = ParseDuration("d")
+	assert.NotNil(t, err)
+}
+
+func Test_MatchDuration(t *testing.T) {
+	assert.True(t, MatchDuration(2*time.Hour, Filter{Operator: OpGreater, Values: []string{"1h"}}))
+	assert.False(t, MatchDuration(2*time.Hour, Filter{Operator: OpLess, Values: []string{"5m"}}))
+	assert.True(t, MatchDuration(48*time.Hour, Filter{Operator: NoOp, Values: []string{"2d"}}))
+	assert.False(t, MatchDuration(time.Hour, Filter{Operator: OpGreater, Values: []string{"bad"}}))
+}
@@ -1,24 +1,47 @@
 module github.com/euforia/metermaid
 
+go 1.27.1
+
 require (
 	github.com/aws/aws-sdk-go v1.17.3
-	github.com/docker/distribution v2.7.1+incompatible // indirect
 	github.com/docker/docker v1.13.1
+	github.com/euforia/gossip v0.6.0
+	github.com/hashicorp/memberlist v0.1.3
+	github.com/shirou/gopsutil v2.18.12+incompatible
+	github.com/stretchr/testify v1.2.2
+	go.uber.org/zap v1.9.1
+	golang.org/x/net v0.0.0-20190213061140-3a22650c66bd
+)
+
+require (
+	github.com/armon/go-metrics v0.0.0-20180917152333-f0300d1749da // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/docker/distribution v2.7.1+incompatible // indirect
 	github.com/docker/go-connections v0.4.0 // indirect
 	github.com/docker/go-units v0.3.3 // indirect
 	github.com/euforia/base58 v0.0.0-20180618003404-b32ada3d7107 // indirect
-	github.com/euforia/gossip v0.6.0
 	github.com/gogo/protobuf v1.2.0 // indirect
-	github.com/hashicorp/memberlist v0.1.3
+	github.com/google/btree v0.0.0-20180813153112-4030bb1f1f0c // indirect
+	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/hashicorp/go-immutable-radix v1.0.0 // indirect
+	github.com/hashicorp/go-msgpack v0.5.3 // indirect
+	github.com/hashicorp/go-multierror v1.0.0 // indirect
+	github.com/hashicorp/go-sockaddr v1.0.0 // indirect
+	github.com/hashicorp/go-uuid v1.0.0 // indirect
+	github.com/hashicorp/golang-lru v0.5.0 // indirect
 	github.com/hexablock/iputil v0.0.0-20190214232959-9da1f0d8e0de // indirect
 	github.com/hexablock/log v0.0.0-20180731202806-50981b397262 // indirect
 	github.com/hexablock/vivaldi v0.0.0-20180727225019-07adad3f2b5f // indirect
+	github.com/jmespath/go-jmespath v0.0.0-20180206201540-c2b33e8439af // indirect
+	github.com/miekg/dns v1.0.14 // indirect
 	github.com/opencontainers/go-digest v1.0.0-rc1 // indirect
+	github.com/pascaldekloe/goe v0.0.0-20180627143212-57f6aae5913c // indirect
 	github.com/pkg/errors v0.8.1 // indirect
-	github.com/shirou/gopsutil v2.18.12+incompatible
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/sean-/seed v0.0.0-20170313163322-e2103e2c3529 // indirect
 	go.uber.org/atomic v1.3.2 // indirect
 	go.uber.org/multierr v1.1.0 // indirect
-	go.uber.org/zap v1.9.1
-	golang.org/x/net v0.0.0-20190213061140-3a22650c66bd // indirect
+	golang.org/x/crypto v0.0.0-20181029021203-45a5f77698d3 // indirect
+	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4 // indirect
 	golang.org/x/sys v0.0.0-20190214214411-e77772198cdc // indirect
 )
@@ -0,0 +1,190 @@
+package metermaid
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/euforia/metermaid/kubelet"
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/types"
+)
+
+// kCollector implements the CCollector interface metering pods from the
+// kubelet rather than their containers.  Each pod is a single container
+// sized by its requests and labeled with its namespace, name and workload
+type kCollector struct {
+	client *kubelet.Client
+
+	// MHz of a single core used to convert cpu requests to shares
+	mhzPerCore float64
+	// Least a pod is metered by
+	min kubelet.Minimum
+
+	// Pods currently tracked by uid
+	pods map[string]*types.Container
+
+	out   chan types.Container
+	stats chan types.ContainerStats
+
+	pollInterval  time.Duration
+	statsInterval time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	log *zap.Logger
+}
+
+// NewKubeletCollector returns a new CCollector polling the kubelet for pods
+// at the poll interval and sampling their usage at the stats interval.  A
+// zero stats interval disables sampling.  Pods requesting less than the
+// minimum e.g. BestEffort pods are metered by the minimum
+func NewKubeletCollector(client *kubelet.Client, nd *node.Node, min kubelet.Minimum, pollInterval, statsInterval time.Duration, logger *zap.Logger) (CCollector, error) {
+	kc := &kCollector{
+		client:        client,
+		mhzPerCore:    float64(nd.CPUShares) / float64(runtime.NumCPU()),
+		min:           min,
+		pods:          make(map[string]*types.Container),
+		out:           make(chan types.Container, 32),
+		stats:         make(chan types.ContainerStats, 32),
+		pollInterval:  pollInterval,
+		statsInterval: statsInterval,
+		done:          make(chan struct{}),
+		log:           logger,
+	}
+	if kc.log == nil {
+		kc.log, _ = zap.NewDevelopment()
+	}
+
+	var ctx context.Context
+	ctx, kc.cancel = context.WithCancel(context.Background())
+	go kc.run(ctx)
+	return kc, nil
+}
+
+func (kc *kCollector) run(ctx context.Context) {
+	defer close(kc.done)
+	defer close(kc.stats)
+	defer close(kc.out)
+
+	poll := time.NewTicker(kc.pollInterval)
+	defer poll.Stop()
+
+	var tick <-chan time.Time
+	if kc.statsInterval > 0 {
+		ticker := time.NewTicker(kc.statsInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	kc.poll(ctx)
+	kc.log.Info("polling kubelet", zap.Duration("interval", kc.pollInterval))
+	for {
+		select {
+		case <-poll.C:
+			kc.poll(ctx)
+
+		case <-tick:
+			kc.sample(ctx)
+
+		case <-ctx.Done():
+			kc.log.Info("kubelet loop exiting")
+			return
+		}
+	}
+}
+
+// poll sends an update for each pod that is new or changed and destroys
+// those no longer bound to the node.  Nothing is destroyed if the kubelet
+// cannot be reached
+func (kc *kCollector) poll(ctx context.Context) {
+	pods, err := kc.client.Pods(ctx)
+	if err != nil {
+		kc.log.Info("failed to list pods", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[string]struct{}, len(pods))
+	for i := range pods {
+		c := pods[i].Container(kc.mhzPerCore, kc.min, now)
+		seen[c.ID] = struct{}{}
+
+		prev, ok := kc.pods[c.ID]
+		if ok && (prev.Destroyed() || reflect.DeepEqual(*prev, c)) {
+			continue
+		}
+		if !ok {
+			kc.log.Debug("tracking", zap.String("pod", c.Name), zap.String("uid", c.ID))
+		}
+		kc.pods[c.ID] = &c
+		kc.send(ctx, c)
+	}
+
+	for uid, c := range kc.pods {
+		if _, ok := seen[uid]; ok {
+			continue
+		}
+		// Once deleted we stop tracking the pod
+		delete(kc.pods, uid)
+		if c.Destroyed() {
+			continue
+		}
+		if c.Stop == 0 {
+			c.Stop = now.UnixNano()
+		}
+		c.Destroy = now.UnixNano()
+		kc.log.Debug("pod deleted", zap.String("pod", c.Name), zap.Duration("alloctime", c.AllocatedTime()))
+		kc.send(ctx, *c)
+	}
+}
+
+// sample sends the usage of the running pods
+func (kc *kCollector) sample(ctx context.Context) {
+	summary, err := kc.client.Summary(ctx)
+	if err != nil {
+		kc.log.Debug("failed to sample", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	for i := range summary.Pods {
+		ps := &summary.Pods[i]
+		c, ok := kc.pods[ps.PodRef.UID]
+		if !ok || c.Destroyed() {
+			continue
+		}
+		select {
+		case kc.stats <- ps.Stats(now):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (kc *kCollector) send(ctx context.Context, c types.Container) {
+	select {
+	case kc.out <- c:
+	case <-ctx.Done():
+	}
+}
+
+func (kc *kCollector) Updates() <-chan types.Container {
+	return kc.out
+}
+
+func (kc *kCollector) Stats() <-chan types.ContainerStats {
+	return kc.stats
+}
+
+func (kc *kCollector) Stop() error {
+	kc.log.Info("stopping")
+	kc.cancel()
+	<-kc.done
+	kc.log.Info("stopped")
+	return nil
+}
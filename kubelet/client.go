@@ -0,0 +1,103 @@
+// Package kubelet implements a minimal client of the kubelet pods and
+// summary stats apis used to meter pods rather than their containers.
+package kubelet
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the kubelet authenticated api on the local node
+const DefaultURL = "https://127.0.0.1:10250"
+
+// Config is the configuration of a kubelet client
+type Config struct {
+	// Base url of the kubelet e.g. https://127.0.0.1:10250 or the read only
+	// http://127.0.0.1:10255.  Defaults to DefaultURL
+	URL string
+	// Optional file holding the bearer token e.g. the service account token
+	TokenFile string
+	// Skip verification of the kubelet serving certificate which is
+	// usually self signed
+	Insecure bool
+	Timeout  time.Duration
+}
+
+// Client is a kubelet api client
+type Client struct {
+	url   string
+	token string
+	hc    *http.Client
+}
+
+// NewClient returns a new kubelet client for the config
+func NewClient(conf Config) (*Client, error) {
+	client := &Client{
+		url: strings.TrimSuffix(conf.URL, "/"),
+		hc:  &http.Client{Timeout: conf.Timeout},
+	}
+	if client.url == "" {
+		client.url = DefaultURL
+	}
+	if client.hc.Timeout == 0 {
+		client.hc.Timeout = 10 * time.Second
+	}
+	if conf.Insecure {
+		client.hc.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	if conf.TokenFile != "" {
+		b, err := ioutil.ReadFile(conf.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		client.token = strings.TrimSpace(string(b))
+	}
+	return client, nil
+}
+
+// Pods returns the pods bound to the node
+func (client *Client) Pods(ctx context.Context) ([]Pod, error) {
+	var list PodList
+	err := client.get(ctx, "/pods", &list)
+	return list.Items, err
+}
+
+// Summary returns the resource usage of the pods on the node
+func (client *Client) Summary(ctx context.Context) (*Summary, error) {
+	var summary Summary
+	if err := client.get(ctx, "/stats/summary", &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (client *Client) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequest("GET", client.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if client.token != "" {
+		req.Header.Set("Authorization", "Bearer "+client.token)
+	}
+
+	resp, err := client.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("kubelet %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,159 @@
+package kubelet
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPods = `{"items":[{
+	"metadata":{
+		"name":"web-7d9f8b6c5-x2x4z","namespace":"shop","uid":"0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b",
+		"labels":{"app":"web","pod-template-hash":"7d9f8b6c5"},
+		"creationTimestamp":"2024-03-01T10:00:00Z",
+		"ownerReferences":[{"kind":"ReplicaSet","name":"web-7d9f8b6c5","controller":true}]
+	},
+	"spec":{
+		"containers":[
+			{"name":"app","resources":{"requests":{"cpu":"500m","memory":"256Mi"}}},
+			{"name":"sidecar","resources":{"limits":{"cpu":"250m","memory":"64Mi"}}}
+		],
+		"initContainers":[{"name":"init","resources":{"requests":{"cpu":"1","memory":"128Mi"}}}],
+		"overhead":{"cpu":"50m"}
+	},
+	"status":{"phase":"Running","startTime":"2024-03-01T10:00:05Z"}
+},{
+	"metadata":{
+		"name":"report-28500000-abcde","namespace":"batch","uid":"uid-2",
+		"creationTimestamp":"2024-03-01T09:00:00Z",
+		"ownerReferences":[{"kind":"Job","name":"report-28500000","controller":true}]
+	},
+	"spec":{"containers":[{"name":"report","resources":{"requests":{"cpu":"2","memory":"1Gi"}}}]},
+	"status":{
+		"phase":"Succeeded","startTime":"2024-03-01T09:00:01Z",
+		"containerStatuses":[{"name":"report","state":{"terminated":{"exitCode":0,"finishedAt":"2024-03-01T09:30:00Z"}}}]
+	}
+}]}`
+
+const testSummary = `{"pods":[{
+	"podRef":{"name":"web-7d9f8b6c5-x2x4z","namespace":"shop","uid":"0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b"},
+	"network":{"time":"2024-03-01T10:05:00Z","rxBytes":100,"txBytes":200},
+	"ephemeral-storage":{"usedBytes":4096}
+}]}`
+
+func Test_ParseQuantity(t *testing.T) {
+	for in, want := range map[string]float64{
+		"250m":  0.25,
+		"1.5":   1.5,
+		"2":     2,
+		"1e3":   1000,
+		"128Mi": 128 << 20,
+		"1Gi":   1 << 30,
+		"1G":    1e9,
+		"500k":  500e3,
+	} {
+		got, err := ParseQuantity(in)
+		assert.Nil(t, err, in)
+		assert.InDelta(t, want, got, 1e-9, in)
+	}
+
+	_, err := ParseQuantity("lots")
+	assert.NotNil(t, err)
+}
+
+func Test_Client(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(401)
+			return
+		}
+		switch r.URL.Path {
+		case "/pods":
+			w.Write([]byte(testPods))
+		case "/stats/summary":
+			w.Write([]byte(testSummary))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer srv.Close()
+
+	token := filepath.Join(t.TempDir(), "token")
+	ioutil.WriteFile(token, []byte("secret\n"), 0600)
+
+	client, err := NewClient(Config{URL: srv.URL + "/", TokenFile: token})
+	assert.Nil(t, err)
+
+	pods, err := client.Pods(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pods))
+
+	summary, err := client.Summary(context.Background())
+	assert.Nil(t, err)
+	stats := summary.Pods[0].Stats(time.Now())
+	assert.Equal(t, "0f1e2d3c-4b5a-6978-8a9b-0c1d2e3f4a5b", stats.ID)
+	assert.Equal(t, uint64(100), stats.NetRxBytes)
+	assert.Equal(t, uint64(200), stats.NetTxBytes)
+	assert.Equal(t, int64(4096), stats.WritableLayerBytes)
+
+	os.Remove(token)
+	client, _ = NewClient(Config{URL: srv.URL})
+	_, err = client.Pods(context.Background())
+	assert.NotNil(t, err)
+}
+
+func Test_Pod_Container(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testPods))
+	}))
+	defer srv.Close()
+	client, _ := NewClient(Config{URL: srv.URL})
+	pods, _ := client.Pods(context.Background())
+
+	now := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)
+	web := pods[0].Container(2000, DefaultMinimum, now)
+	// Init container cpu exceeds the sum of containers, memory does not
+	assert.Equal(t, int64(1.05*2000), web.CPUShares)
+	assert.Equal(t, int64(320<<20), web.Memory)
+	assert.Equal(t, "shop/web-7d9f8b6c5-x2x4z", web.Name)
+	assert.Equal(t, "Deployment", web.Labels[LabelWorkloadKind])
+	assert.Equal(t, "web", web.Labels[LabelWorkloadName])
+	assert.Equal(t, "shop", web.Labels[LabelPodNamespace])
+	assert.Equal(t, "web", web.Labels["app"])
+	assert.False(t, web.Destroyed())
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 5, 0, time.UTC).UnixNano(), web.Start)
+
+	report := pods[1].Container(2000, DefaultMinimum, now)
+	assert.Equal(t, "CronJob", report.Labels[LabelWorkloadKind])
+	assert.Equal(t, "report", report.Labels[LabelWorkloadName])
+	finished := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC).UnixNano()
+	assert.Equal(t, finished, report.Stop)
+	assert.Equal(t, finished, report.Destroy)
+
+	bare := Pod{Metadata: ObjectMeta{Name: "debug"}}
+	kind, name := bare.Workload()
+	assert.Equal(t, "Pod", kind)
+	assert.Equal(t, "debug", name)
+
+	// BestEffort pods are metered by the minimum rather than nothing
+	be := bare.Container(2000, DefaultMinimum, now)
+	assert.Equal(t, int64(0.01*2000), be.CPUShares)
+	assert.Equal(t, int64(16<<20), be.Memory)
+	be = bare.Container(2000, Minimum{CPU: 0.5}, now)
+	assert.Equal(t, int64(1000), be.CPUShares)
+	assert.Equal(t, int64(0), be.Memory)
+}
+
+func Test_Infrastructure(t *testing.T) {
+	assert.True(t, Infrastructure(map[string]string{"io.kubernetes.docker.type": "podsandbox"}))
+	assert.True(t, Infrastructure(map[string]string{"io.kubernetes.container.name": "POD"}))
+	assert.False(t, Infrastructure(map[string]string{"io.kubernetes.container.name": "app"}))
+	assert.False(t, Infrastructure(nil))
+}
@@ -0,0 +1,230 @@
+package kubelet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/euforia/metermaid/types"
+)
+
+// Labels set on pods metered from the kubelet.  The pod labels match those
+// docker sets on the containers of a pod so queries work with either
+const (
+	LabelPodName      = "io.kubernetes.pod.name"
+	LabelPodNamespace = "io.kubernetes.pod.namespace"
+	LabelPodUID       = "io.kubernetes.pod.uid"
+	// Kind and name of the workload owning the pod e.g. Deployment/web
+	LabelWorkloadKind = "io.kubernetes.workload.kind"
+	LabelWorkloadName = "io.kubernetes.workload.name"
+)
+
+// Docker labels identifying the pause container holding the pod namespaces
+const (
+	dockerTypeLabel      = "io.kubernetes.docker.type"
+	dockerContainerLabel = "io.kubernetes.container.name"
+)
+
+// Pod phases
+const (
+	PodSucceeded = "Succeeded"
+	PodFailed    = "Failed"
+)
+
+// Infrastructure returns true if the docker labels are of a pod
+// infrastructure (pause) container.  These hold no workload and would skew
+// pod costs
+func Infrastructure(labels map[string]string) bool {
+	return labels[dockerTypeLabel] == "podsandbox" || labels[dockerContainerLabel] == "POD"
+}
+
+// quantity suffixes and their multipliers
+var (
+	binarySuffixes = map[string]float64{
+		"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30,
+		"Ti": 1 << 40, "Pi": 1 << 50, "Ei": 1 << 60,
+	}
+	decimalSuffixes = map[string]float64{
+		"m": 1e-3, "k": 1e3, "M": 1e6, "G": 1e9,
+		"T": 1e12, "P": 1e15, "E": 1e18,
+	}
+)
+
+// ParseQuantity parses a kubernetes resource quantity e.g. 250m, 1.5,
+// 512Mi or 1e3
+func ParseQuantity(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+
+	if len(s) > 2 {
+		if mult, ok := binarySuffixes[s[len(s)-2:]]; ok {
+			v, err := strconv.ParseFloat(s[:len(s)-2], 64)
+			return v * mult, err
+		}
+	}
+	if len(s) > 1 {
+		if mult, ok := decimalSuffixes[s[len(s)-1:]]; ok {
+			v, err := strconv.ParseFloat(s[:len(s)-1], 64)
+			return v * mult, err
+		}
+	}
+	return 0, fmt.Errorf("invalid quantity: %q", s)
+}
+
+// request returns the requested quantity of the resource defaulting to the
+// limit as kubernetes does when only a limit is set
+func (c *Container) request(resource string) float64 {
+	q, ok := c.Resources.Requests[resource]
+	if !ok {
+		q = c.Resources.Limits[resource]
+	}
+	v, _ := ParseQuantity(q)
+	return v
+}
+
+// Requests returns the cpu cores and memory bytes reserved for the pod by
+// the scheduler.  This is the larger of the sum of the containers and the
+// largest init container plus any pod overhead
+func (pod *Pod) Requests() (cpu, mem float64) {
+	for i := range pod.Spec.Containers {
+		cpu += pod.Spec.Containers[i].request("cpu")
+		mem += pod.Spec.Containers[i].request("memory")
+	}
+	for i := range pod.Spec.InitContainers {
+		cpu = maxFloat(cpu, pod.Spec.InitContainers[i].request("cpu"))
+		mem = maxFloat(mem, pod.Spec.InitContainers[i].request("memory"))
+	}
+	ocpu, _ := ParseQuantity(pod.Spec.Overhead["cpu"])
+	omem, _ := ParseQuantity(pod.Spec.Overhead["memory"])
+	return cpu + ocpu, mem + omem
+}
+
+// Minimum is the least cpu cores and memory bytes a pod is metered by.
+// BestEffort pods request neither but still share the node
+type Minimum struct {
+	CPU    float64
+	Memory float64
+}
+
+// DefaultMinimum meters pods without requests by the cpu kubernetes gives
+// them a share of and a small amount of memory
+var DefaultMinimum = Minimum{CPU: 0.01, Memory: 16 << 20}
+
+// Workload returns the kind and name of the workload owning the pod.
+// Replica sets of deployments and jobs of cron jobs resolve to their owner
+// by the names kubernetes generates.  Pods without an owner are their own
+// workload
+func (pod *Pod) Workload() (kind, name string) {
+	refs := pod.Metadata.OwnerReferences
+	if len(refs) == 0 {
+		return "Pod", pod.Metadata.Name
+	}
+	owner := refs[0]
+	for _, ref := range refs {
+		if ref.Controller != nil && *ref.Controller {
+			owner = ref
+			break
+		}
+	}
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		hash := pod.Metadata.Labels["pod-template-hash"]
+		if hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	case "Job":
+		// Cron jobs suffix the scheduled time in minutes
+		if i := strings.LastIndexByte(owner.Name, '-'); i > 0 && len(owner.Name)-i > 8 {
+			if _, err := strconv.ParseUint(owner.Name[i+1:], 10, 64); err == nil {
+				return "CronJob", owner.Name[:i]
+			}
+		}
+	}
+	return owner.Kind, owner.Name
+}
+
+// Terminal returns true if all containers of the pod have exited and will
+// not be restarted
+func (pod *Pod) Terminal() bool {
+	return pod.Status.Phase == PodSucceeded || pod.Status.Phase == PodFailed
+}
+
+// finished returns the time the last container of the pod exited
+func (pod *Pod) finished() time.Time {
+	var t time.Time
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Terminated != nil && cs.State.Terminated.FinishedAt.After(t) {
+			t = cs.State.Terminated.FinishedAt
+		}
+	}
+	return t
+}
+
+// Container returns the pod as a container sized by its requests raised to
+// the minimum.  cpu is converted to shares using the MHz of a single core
+// of the node.  Terminal pods are destroyed as of when they finished as the
+// scheduler releases their requests, using now if the finish time is
+// unknown
+func (pod *Pod) Container(mhzPerCore float64, min Minimum, now time.Time) types.Container {
+	cpu, mem := pod.Requests()
+	cpu, mem = maxFloat(cpu, min.CPU), maxFloat(mem, min.Memory)
+	c := types.Container{
+		ID:        pod.Metadata.UID,
+		Name:      pod.Metadata.Namespace + "/" + pod.Metadata.Name,
+		Create:    pod.Metadata.CreationTimestamp.UnixNano(),
+		CPUShares: int64(cpu * mhzPerCore),
+		Memory:    int64(mem),
+		Labels:    make(map[string]string, len(pod.Metadata.Labels)+5),
+	}
+	if pod.Status.StartTime != nil {
+		c.Start = pod.Status.StartTime.UnixNano()
+	}
+	if pod.Terminal() {
+		finished := pod.finished()
+		if finished.IsZero() {
+			finished = now
+		}
+		c.Stop = finished.UnixNano()
+		c.Destroy = c.Stop
+	}
+
+	for k, v := range pod.Metadata.Labels {
+		c.Labels[k] = v
+	}
+	c.Labels[LabelPodName] = pod.Metadata.Name
+	c.Labels[LabelPodNamespace] = pod.Metadata.Namespace
+	c.Labels[LabelPodUID] = pod.Metadata.UID
+	c.Labels[LabelWorkloadKind], c.Labels[LabelWorkloadName] = pod.Workload()
+	return c
+}
+
+// Stats returns the usage sample of the pod
+func (ps *PodStats) Stats(now time.Time) types.ContainerStats {
+	stats := types.ContainerStats{ID: ps.PodRef.UID, Timestamp: now.UnixNano()}
+	if n := ps.Network; n != nil {
+		if !n.Time.IsZero() {
+			stats.Timestamp = n.Time.UnixNano()
+		}
+		if n.RxBytes != nil {
+			stats.NetRxBytes = *n.RxBytes
+		}
+		if n.TxBytes != nil {
+			stats.NetTxBytes = *n.TxBytes
+		}
+	}
+	if fs := ps.EphemeralStorage; fs != nil && fs.UsedBytes != nil {
+		stats.WritableLayerBytes = int64(*fs.UsedBytes)
+	}
+	return stats
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
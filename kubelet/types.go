@@ -0,0 +1,116 @@
+package kubelet
+
+import "time"
+
+// The subset of the kubernetes core v1 and kubelet summary api types used
+// for metering
+
+// PodList is the response of the kubelet pods api
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// Pod is a kubernetes pod
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     PodSpec    `json:"spec"`
+	Status   PodStatus  `json:"status"`
+}
+
+// ObjectMeta is the metadata of a kubernetes object
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	UID               string            `json:"uid"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+	DeletionTimestamp *time.Time        `json:"deletionTimestamp,omitempty"`
+	OwnerReferences   []OwnerReference  `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference identifies the object owning another
+type OwnerReference struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Controller *bool  `json:"controller,omitempty"`
+}
+
+// PodSpec is the desired state of a pod
+type PodSpec struct {
+	NodeName       string       `json:"nodeName,omitempty"`
+	Containers     []Container  `json:"containers"`
+	InitContainers []Container  `json:"initContainers,omitempty"`
+	Overhead       ResourceList `json:"overhead,omitempty"`
+}
+
+// Container is a container in a pod spec
+type Container struct {
+	Name      string               `json:"name"`
+	Image     string               `json:"image"`
+	Resources ResourceRequirements `json:"resources"`
+}
+
+// ResourceRequirements are the requests and limits of a container
+type ResourceRequirements struct {
+	Requests ResourceList `json:"requests,omitempty"`
+	Limits   ResourceList `json:"limits,omitempty"`
+}
+
+// ResourceList maps resource names to quantities e.g. cpu=500m
+type ResourceList map[string]string
+
+// PodStatus is the observed state of a pod
+type PodStatus struct {
+	Phase             string            `json:"phase"`
+	StartTime         *time.Time        `json:"startTime,omitempty"`
+	ContainerStatuses []ContainerStatus `json:"containerStatuses,omitempty"`
+}
+
+// ContainerStatus is the observed state of a container in a pod
+type ContainerStatus struct {
+	Name  string         `json:"name"`
+	State ContainerState `json:"state"`
+}
+
+// ContainerState holds the terminated state of a container if it exited
+type ContainerState struct {
+	Terminated *ContainerStateTerminated `json:"terminated,omitempty"`
+}
+
+// ContainerStateTerminated is the state of an exited container
+type ContainerStateTerminated struct {
+	ExitCode   int       `json:"exitCode"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// Summary is the response of the kubelet summary stats api
+type Summary struct {
+	Pods []PodStats `json:"pods"`
+}
+
+// PodStats is the resource usage of a pod
+type PodStats struct {
+	PodRef           PodReference  `json:"podRef"`
+	Network          *NetworkStats `json:"network,omitempty"`
+	EphemeralStorage *FsStats      `json:"ephemeral-storage,omitempty"`
+}
+
+// PodReference identifies the pod of the stats
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
+}
+
+// NetworkStats are the cumulative network counters of a pod
+type NetworkStats struct {
+	Time    time.Time `json:"time"`
+	RxBytes *uint64   `json:"rxBytes,omitempty"`
+	TxBytes *uint64   `json:"txBytes,omitempty"`
+}
+
+// FsStats is the usage of a filesystem
+type FsStats struct {
+	Time      time.Time `json:"time"`
+	UsedBytes *uint64   `json:"usedBytes,omitempty"`
+}
@@ -0,0 +1,68 @@
+package metermaid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/euforia/metermaid/kubelet"
+	"github.com/euforia/metermaid/types"
+)
+
+const testKubeletPod = `{
+	"metadata":{"name":"web-0","namespace":"shop","uid":"uid-1","creationTimestamp":"2024-03-01T10:00:00Z"},
+	"spec":{"containers":[{"name":"app","resources":{"requests":{"cpu":"500m","memory":"256Mi"}}}]},
+	"status":{"phase":"Running","startTime":"2024-03-01T10:00:05Z"}
+}`
+
+func Test_kCollector_poll(t *testing.T) {
+	pods := `{"items":[` + testKubeletPod + `]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pods))
+	}))
+	defer srv.Close()
+
+	client, err := kubelet.NewClient(kubelet.Config{URL: srv.URL})
+	assert.Nil(t, err)
+	kc := &kCollector{
+		client:     client,
+		mhzPerCore: 2000,
+		pods:       make(map[string]*types.Container),
+		out:        make(chan types.Container, 32),
+		log:        zap.NewNop(),
+	}
+	ctx := context.Background()
+
+	// New pods are sent once until they change
+	kc.poll(ctx)
+	kc.poll(ctx)
+	assert.Equal(t, 1, len(kc.out))
+	c := <-kc.out
+	assert.Equal(t, "uid-1", c.ID)
+	assert.Equal(t, "shop/web-0", c.Name)
+	assert.Equal(t, int64(1000), c.CPUShares)
+	assert.Equal(t, int64(256<<20), c.Memory)
+	assert.False(t, c.Destroyed())
+
+	// Pods no longer bound to the node are destroyed
+	pods = `{"items":[]}`
+	kc.poll(ctx)
+	assert.Equal(t, 1, len(kc.out))
+	c = <-kc.out
+	assert.Equal(t, "uid-1", c.ID)
+	assert.True(t, c.Destroyed())
+	assert.Equal(t, 0, len(kc.pods))
+
+	// Nothing is destroyed when the kubelet cannot be reached
+	pods = `{"items":[` + testKubeletPod + `]}`
+	kc.poll(ctx)
+	<-kc.out
+	srv.Close()
+	kc.poll(ctx)
+	assert.Equal(t, 0, len(kc.out))
+	assert.Equal(t, 1, len(kc.pods))
+}
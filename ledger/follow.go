@@ -0,0 +1,51 @@
+package ledger
+
+import (
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/storage"
+	"github.com/euforia/metermaid/types"
+)
+
+// destroyedExpr matches finalized containers
+var destroyedExpr = &fl.FieldExpr{
+	Field:  "Destroy",
+	Filter: fl.Filter{Operator: fl.OpGreater, Values: []string{"1970-01-01T00:00:00Z"}},
+}
+
+// Follow adds the destroyed containers in the store to the ledger and then
+// each container as it is destroyed or its final cost is updated, until the
+// ledger is stopped.  If the watch overflows the store is rescanned.
+// Containers the ledger already holds unchanged keep their version and are
+// not pushed again
+func (l *Ledger) Follow(store storage.Containers) {
+	for {
+		watch := store.Watch(destroyedExpr)
+		store.Query(destroyedExpr, func(c types.Container) error {
+			l.Add(c)
+			return nil
+		})
+
+		if !l.follow(watch) {
+			watch.Close()
+			return
+		}
+		l.log.Info("ledger watch overflowed, rescanning containers")
+	}
+}
+
+// follow adds containers from the watch returning false once stopped
+func (l *Ledger) follow(watch *storage.Watch) bool {
+	for {
+		select {
+		case ev, ok := <-watch.Events():
+			if !ok {
+				return true
+			}
+			if ev.Type != storage.EventRemoved {
+				l.Add(ev.Container)
+			}
+		case <-l.stop:
+			return false
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package ledger
+
+import (
+	"errors"
+
+	"github.com/euforia/gossip"
+)
+
+// GossipTransport implements the Transport interface using the reliable
+// user messages of a gossip pool
+type GossipTransport struct {
+	pool *gossip.Pool
+}
+
+// NewGossipTransport returns a new GossipTransport for the pool
+func NewGossipTransport(pool *gossip.Pool) *GossipTransport {
+	return &GossipTransport{pool: pool}
+}
+
+// LocalName satisfies the Transport interface
+func (gt *GossipTransport) LocalName() string {
+	return gt.pool.LocalNode().Name
+}
+
+// Members satisfies the Transport interface
+func (gt *GossipTransport) Members() []string {
+	members := gt.pool.Members()
+	names := make([]string, len(members))
+	for i, m := range members {
+		names[i] = m.Name
+	}
+	return names
+}
+
+// Send satisfies the Transport interface
+func (gt *GossipTransport) Send(to string, msg []byte) error {
+	for _, m := range gt.pool.Members() {
+		if m.Name == to {
+			return gt.pool.SendReliable(m, msg)
+		}
+	}
+	return errors.New("unknown member: " + to)
+}
@@ -0,0 +1,357 @@
+// Package ledger replicates finalized container records across the cluster
+// so cost history survives the loss of the node that ran them.
+//
+// Each record is held by the node it originated on and the N peers ranked
+// highest for its key by rendezvous hashing over the live members.  Holders
+// periodically send digests of their records to the other holders, which
+// request any they are missing or have older versions of.  When membership
+// changes the ranking changes and the surviving holders repair the new
+// replicas on the next round.
+package ledger
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/types"
+	"go.uber.org/zap"
+)
+
+// msgPrefix identifies ledger messages among other user messages
+const msgPrefix byte = 'L'
+
+// digestBatch is the maximum number of digests sent in one message
+const digestBatch = 1000
+
+// ErrNotLedgerMessage is returned when handling a message that is not for
+// the ledger
+var ErrNotLedgerMessage = errors.New("not a ledger message")
+
+// Transport sends messages to peers by name
+type Transport interface {
+	// LocalName returns the name of the local node
+	LocalName() string
+	// Members returns the names of the live members including the local
+	// node
+	Members() []string
+	// Send reliably sends the message to the named member
+	Send(to string, msg []byte) error
+}
+
+// Record is a finalized container record
+type Record struct {
+	// Name of the node that ran the container
+	Origin    string
+	Container types.Container
+	// Time the record was last written at the origin in epoch nano.  The
+	// latest version wins
+	Updated int64
+}
+
+// Key uniquely identifies the record in the cluster
+func (rec *Record) Key() string {
+	return rec.Origin + "/" + rec.Container.ID
+}
+
+func (rec *Record) digest() string {
+	b, _ := json.Marshal(rec)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+type msgType string
+
+const (
+	msgPush   msgType = "push"
+	msgDigest msgType = "digest"
+	msgWant   msgType = "want"
+)
+
+type message struct {
+	Type msgType
+	From string
+	// Set for push
+	Records []Record `json:",omitempty"`
+	// Record digests by key for digest
+	Digests map[string]string `json:",omitempty"`
+	// Keys requested for want
+	Keys []string `json:",omitempty"`
+}
+
+func encode(msg *message) []byte {
+	b, _ := json.Marshal(msg)
+	return append([]byte{msgPrefix}, b...)
+}
+
+// Ledger holds the local and replicated container records
+type Ledger struct {
+	replicas int
+	// Records of containers destroyed longer ago are dropped.  Zero keeps
+	// all
+	maxAge time.Duration
+	trans  Transport
+
+	mu      sync.RWMutex
+	records map[string]Record
+
+	stop chan struct{}
+	log  *zap.Logger
+}
+
+// New returns a ledger keeping each record on the given number of peers in
+// addition to its origin, until its container was destroyed more than
+// maxAge ago.  A zero maxAge keeps records forever
+func New(replicas int, maxAge time.Duration, trans Transport, logger *zap.Logger) *Ledger {
+	return &Ledger{
+		replicas: replicas,
+		maxAge:   maxAge,
+		trans:    trans,
+		records:  make(map[string]Record),
+		stop:     make(chan struct{}),
+		log:      logger,
+	}
+}
+
+// Add records a finalized container that ran on the local node and pushes
+// it to its replicas.  Containers already recorded as is are skipped so
+// their version is kept
+func (l *Ledger) Add(c types.Container) {
+	now := time.Now()
+	rec := Record{
+		Origin:    l.trans.LocalName(),
+		Container: c,
+		Updated:   now.UnixNano(),
+	}
+	if l.expired(&rec, now) {
+		return
+	}
+
+	l.mu.Lock()
+	if existing, ok := l.records[rec.Key()]; ok && reflect.DeepEqual(existing.Container, c) {
+		l.mu.Unlock()
+		return
+	}
+	l.records[rec.Key()] = rec
+	l.mu.Unlock()
+
+	for _, peer := range l.holders(rec.Key(), rec.Origin, l.trans.Members()) {
+		if peer == rec.Origin {
+			continue
+		}
+		l.send(peer, &message{Type: msgPush, Records: []Record{rec}})
+	}
+}
+
+// Get returns the record by origin and container id
+func (l *Ledger) Get(origin, id string) (Record, bool) {
+	l.mu.RLock()
+	rec, ok := l.records[origin+"/"+id]
+	l.mu.RUnlock()
+	return rec, ok
+}
+
+// Query calls the function with each record whose container matches the
+// expression
+func (l *Ledger) Query(expr fl.Expr, f func(Record) error) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, rec := range l.records {
+		if !expr.Eval(&rec.Container) {
+			continue
+		}
+		if err := f(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// holders returns the origin and the peers ranked highest for the key by
+// rendezvous hashing over the live members
+func (l *Ledger) holders(key, origin string, members []string) []string {
+	type scored struct {
+		name  string
+		score uint64
+	}
+	peers := make([]scored, 0, len(members))
+	for _, m := range members {
+		if m == origin {
+			continue
+		}
+		sum := sha256.Sum256([]byte(key + "\x00" + m))
+		peers = append(peers, scored{m, binary.BigEndian.Uint64(sum[:8])})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].score > peers[j].score })
+
+	out := []string{origin}
+	for i := 0; i < len(peers) && i < l.replicas; i++ {
+		out = append(out, peers[i].name)
+	}
+	return out
+}
+
+// HandleMessage handles a ledger message from a peer
+func (l *Ledger) HandleMessage(data []byte) error {
+	if len(data) == 0 || data[0] != msgPrefix {
+		return ErrNotLedgerMessage
+	}
+	var msg message
+	if err := json.Unmarshal(data[1:], &msg); err != nil {
+		return err
+	}
+
+	switch msg.Type {
+	case msgPush:
+		l.merge(msg.Records)
+
+	case msgDigest:
+		want := l.missing(msg.Digests)
+		if len(want) > 0 {
+			l.send(msg.From, &message{Type: msgWant, Keys: want})
+		}
+
+	case msgWant:
+		l.mu.RLock()
+		recs := make([]Record, 0, len(msg.Keys))
+		for _, key := range msg.Keys {
+			if rec, ok := l.records[key]; ok {
+				recs = append(recs, rec)
+			}
+		}
+		l.mu.RUnlock()
+		if len(recs) > 0 {
+			l.send(msg.From, &message{Type: msgPush, Records: recs})
+		}
+	}
+	return nil
+}
+
+// merge stores the records that are new or newer than those held and not
+// expired
+func (l *Ledger) merge(recs []Record) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, rec := range recs {
+		if l.expired(&rec, now) {
+			continue
+		}
+		if existing, ok := l.records[rec.Key()]; ok && existing.Updated >= rec.Updated {
+			continue
+		}
+		l.records[rec.Key()] = rec
+	}
+}
+
+// expired returns true if the container of the record was destroyed more
+// than the max age before now
+func (l *Ledger) expired(rec *Record, now time.Time) bool {
+	return l.maxAge > 0 && rec.Container.Destroy > 0 &&
+		now.Sub(time.Unix(0, rec.Container.Destroy)) > l.maxAge
+}
+
+// Expire drops the expired records returning the number dropped
+func (l *Ledger) Expire(now time.Time) int {
+	if l.maxAge <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var n int
+	for key, rec := range l.records {
+		if l.expired(&rec, now) {
+			delete(l.records, key)
+			n++
+		}
+	}
+	return n
+}
+
+// missing returns the keys of the digests that are not held or differ
+func (l *Ledger) missing(digests map[string]string) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var want []string
+	for key, d := range digests {
+		if rec, ok := l.records[key]; !ok || rec.digest() != d {
+			want = append(want, key)
+		}
+	}
+	sort.Strings(want)
+	return want
+}
+
+// AntiEntropy sends the digests of held records to the other holders of
+// each record so they can request what they are missing
+func (l *Ledger) AntiEntropy() {
+	self := l.trans.LocalName()
+	members := l.trans.Members()
+	byPeer := make(map[string]map[string]string)
+
+	l.mu.RLock()
+	for key, rec := range l.records {
+		for _, peer := range l.holders(key, rec.Origin, members) {
+			if peer == self {
+				continue
+			}
+			digests, ok := byPeer[peer]
+			if !ok {
+				digests = make(map[string]string)
+				byPeer[peer] = digests
+			}
+			digests[key] = rec.digest()
+		}
+	}
+	l.mu.RUnlock()
+
+	for peer, digests := range byPeer {
+		batch := make(map[string]string, digestBatch)
+		for key, d := range digests {
+			batch[key] = d
+			if len(batch) == digestBatch {
+				l.send(peer, &message{Type: msgDigest, Digests: batch})
+				batch = make(map[string]string, digestBatch)
+			}
+		}
+		if len(batch) > 0 {
+			l.send(peer, &message{Type: msgDigest, Digests: batch})
+		}
+	}
+}
+
+// Run expires records and runs anti-entropy at the interval until stopped
+func (l *Ledger) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if n := l.Expire(time.Now()); n > 0 {
+				l.log.Debug("ledger records expired", zap.Int("count", n))
+			}
+			l.AntiEntropy()
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the anti-entropy loop
+func (l *Ledger) Stop() {
+	close(l.stop)
+}
+
+func (l *Ledger) send(to string, msg *message) {
+	msg.From = l.trans.LocalName()
+	if err := l.trans.Send(to, encode(msg)); err != nil {
+		l.log.Debug("ledger send failed", zap.String("to", to), zap.Error(err))
+	}
+}
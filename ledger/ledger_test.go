@@ -0,0 +1,149 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// cluster delivers messages between ledgers synchronously
+type cluster struct {
+	ledgers map[string]*Ledger
+}
+
+type testTransport struct {
+	name string
+	c    *cluster
+}
+
+func (tt *testTransport) LocalName() string { return tt.name }
+
+func (tt *testTransport) Members() []string {
+	names := make([]string, 0, len(tt.c.ledgers))
+	for name := range tt.c.ledgers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (tt *testTransport) Send(to string, msg []byte) error {
+	l, ok := tt.c.ledgers[to]
+	if !ok {
+		return errors.New("unknown member")
+	}
+	return l.HandleMessage(msg)
+}
+
+func newCluster(n, replicas int) *cluster {
+	c := &cluster{ledgers: make(map[string]*Ledger)}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("node%d", i)
+		c.ledgers[name] = New(replicas, 0, &testTransport{name, c}, zap.NewNop())
+	}
+	return c
+}
+
+// holding returns the number of ledgers holding the record
+func (c *cluster) holding(origin, id string) int {
+	var n int
+	for _, l := range c.ledgers {
+		if _, ok := l.Get(origin, id); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func Test_Ledger_Replicate(t *testing.T) {
+	c := newCluster(5, 2)
+	for i := 0; i < 20; i++ {
+		c.ledgers["node0"].Add(types.Container{ID: fmt.Sprintf("c%02d", i), Destroy: 10})
+	}
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, 3, c.holding("node0", fmt.Sprintf("c%02d", i)))
+	}
+
+	var n int
+	c.ledgers["node0"].Query(fl.TrueExpr{}, func(Record) error { n++; return nil })
+	assert.Equal(t, 20, n)
+
+	assert.Equal(t, ErrNotLedgerMessage, c.ledgers["node0"].HandleMessage([]byte("{}")))
+}
+
+func Test_Ledger_Repair(t *testing.T) {
+	c := newCluster(5, 2)
+	for i := 0; i < 20; i++ {
+		c.ledgers["node0"].Add(types.Container{ID: fmt.Sprintf("c%02d", i), Destroy: 10})
+	}
+
+	// Losing the origin leaves the replicas to repair each other
+	delete(c.ledgers, "node0")
+	for _, l := range c.ledgers {
+		l.AntiEntropy()
+	}
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, 2, c.holding("node0", fmt.Sprintf("c%02d", i)))
+	}
+
+	// A replica is lost and a new holder is chosen from the remaining
+	delete(c.ledgers, "node1")
+	for _, l := range c.ledgers {
+		l.AntiEntropy()
+	}
+	for i := 0; i < 20; i++ {
+		assert.True(t, c.holding("node0", fmt.Sprintf("c%02d", i)) >= 2)
+	}
+}
+
+func Test_Ledger_Merge(t *testing.T) {
+	c := newCluster(2, 1)
+	a, b := c.ledgers["node0"], c.ledgers["node1"]
+
+	a.Add(types.Container{ID: "c", Destroy: 10})
+	a.Add(types.Container{ID: "c", Destroy: 10, UnitsBurned: 2})
+	rec, _ := b.Get("node0", "c")
+	assert.Equal(t, 2.0, rec.Container.UnitsBurned)
+
+	// Older versions are ignored
+	old := rec
+	old.Updated--
+	old.Container.UnitsBurned = 1
+	b.merge([]Record{old})
+	rec, _ = b.Get("node0", "c")
+	assert.Equal(t, 2.0, rec.Container.UnitsBurned)
+}
+
+func Test_Ledger_Unchanged(t *testing.T) {
+	c := newCluster(2, 1)
+	a := c.ledgers["node0"]
+
+	a.Add(types.Container{ID: "c", Destroy: 10, UnitsBurned: 2})
+	rec, _ := a.Get("node0", "c")
+	a.Add(types.Container{ID: "c", Destroy: 10, UnitsBurned: 2})
+	again, _ := a.Get("node0", "c")
+	assert.Equal(t, rec.Updated, again.Updated)
+}
+
+func Test_Ledger_Expire(t *testing.T) {
+	now := time.Now()
+	l := New(0, time.Hour, &testTransport{"node0", &cluster{}}, zap.NewNop())
+	old := now.Add(-2 * time.Hour).UnixNano()
+
+	l.Add(types.Container{ID: "old", Destroy: old})
+	l.records["node0/stale"] = Record{Origin: "node0", Container: types.Container{ID: "stale", Destroy: old}}
+	l.Add(types.Container{ID: "new", Destroy: now.UnixNano()})
+	l.merge([]Record{{Origin: "node1", Container: types.Container{ID: "old", Destroy: old}}})
+
+	assert.Equal(t, 1, l.Expire(now))
+	_, ok := l.Get("node0", "new")
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(l.records))
+}
@@ -2,11 +2,14 @@ package metermaid
 
 import (
 	"errors"
+	"sync"
 	"time"
 
+	"github.com/euforia/metermaid/energy"
 	"github.com/euforia/metermaid/node"
 	"github.com/euforia/metermaid/pricing"
 	"github.com/euforia/metermaid/storage"
+	"github.com/euforia/metermaid/tsdb"
 	"github.com/euforia/metermaid/types"
 	"go.uber.org/zap"
 )
@@ -15,25 +18,75 @@ import (
 type Metermaid interface {
 	PriceReport(start, end time.Time) (*pricing.Report, error)
 	Containers() storage.Containers
+	// Usage series of containers
+	Series() storage.Series
+	// Rates used to convert costs from the base currency
+	ExchangeRates() *pricing.ExchangeRates
+	// Cached price history of the node
+	Prices() *pricing.Pricer
+	// Node returns the node being metered
+	Node() *node.Node
+	// SetNode replaces the node after its metadata or capacity is
+	// rediscovered
+	SetNode(*node.Node)
+	// Metadata changes of the node
+	MetaHistory() *node.MetaHistory
 }
 
 type Config struct {
 	Node             *node.Node
 	ContainerStorage storage.Containers
 	Pricer           pricing.Provider
-	Collector        CCollector
-	Logger           *zap.Logger
+	// Optional purchased commitments applied to the list price
+	Commitments *pricing.Commitments
+	// Optional pricer for volumes attached to the node
+	StoragePricer pricing.StorageProvider
+	// Weight of block io in the container price between 0 and 1. The rest
+	// is split evenly between cpu and memory. Zero disables io pricing
+	IOWeight float64
+	// Optional egress rates used to price container network traffic
+	EgressRates *pricing.EgressRates
+	// Optional rates to convert costs to other currencies or units
+	ExchangeRates *pricing.ExchangeRates
+	// Optional power model used to estimate energy and carbon
+	Energy *energy.Model
+	// Storage for usage series. Defaults to in memory
+	SeriesStorage storage.Series
+	// Optional bound on destroyed containers kept in ContainerStorage
+	Retention *storage.RetentionPolicy
+	Collector CCollector
+	Logger    *zap.Logger
 }
 
 type meterMaid struct {
+	nmu  sync.RWMutex
 	node *node.Node
+	// Node metadata changes used to tag containers with the meta in effect
+	// when they were created
+	history *node.MetaHistory
 
 	pp *pricing.Pricer
 
 	cpuWeight float64
 	memWeight float64
+	ioWeight  float64
+
+	// Hourly price of node volumes by id
+	volumeRates map[string]float64
+	// When containers mounted each volume by volume and container id.  Only
+	// used by the update loop
+	mounts map[string]map[string][2]int64
+
+	// Egress rates for container traffic. Nil disables network pricing
+	egress *pricing.EgressRates
+
+	// Power models by instance type. Nil disables estimation
+	energy *energy.Model
+
+	rates *pricing.ExchangeRates
 
 	cstore storage.Containers
+	series storage.Series
 	log    *zap.Logger
 }
 
@@ -41,28 +94,98 @@ type meterMaid struct {
 func New(conf *Config) Metermaid {
 	mm := &meterMaid{
 		node:      conf.Node,
+		history:   node.NewMetaHistory(conf.Node.Meta, time.Unix(0, int64(conf.Node.BootTime))),
 		cpuWeight: 0.5,
 		memWeight: 0.5,
 		pp:        pricing.NewPricer(conf.Pricer, *conf.Node, conf.Logger),
+		egress:    conf.EgressRates,
+		energy:    conf.Energy,
+		rates:     conf.ExchangeRates,
 		cstore:    conf.ContainerStorage,
+		series:    conf.SeriesStorage,
 		log:       conf.Logger,
 	}
+	if mm.series == nil {
+		mm.series = storage.NewInmemSeries()
+	}
+	if conf.IOWeight > 0 && conf.IOWeight < 1 {
+		mm.ioWeight = conf.IOWeight
+		mm.cpuWeight = (1 - conf.IOWeight) / 2
+		mm.memWeight = mm.cpuWeight
+	}
+	mm.volumeRates = priceVolumes(conf.Node, conf.StoragePricer, conf.Logger)
+	mm.mounts = make(map[string]map[string][2]int64, len(mm.volumeRates))
+	if len(mm.volumeRates) > 0 {
+		// Stored containers may still share volumes with running ones
+		mm.cstore.Iter(func(c types.Container) error {
+			mm.indexMounts(c)
+			return nil
+		})
+	}
+
+	if conf.Commitments != nil {
+		mm.pp.SetCommitments(conf.Commitments)
+	}
 
 	go mm.run(conf.Collector.Updates())
+	go mm.runStats(conf.Collector.Stats())
+	if conf.Retention != nil {
+		go mm.runRetention(*conf.Retention)
+	}
 
 	return mm
 }
 
+func (mm *meterMaid) Node() *node.Node {
+	mm.nmu.RLock()
+	defer mm.nmu.RUnlock()
+	return mm.node
+}
+
+// SetNode satisfies the Metermaid interface.  The node must not be modified
+// afterwards.  Meta changes apply to prices fetched and containers created
+// from now
+func (mm *meterMaid) SetNode(nd *node.Node) {
+	mm.nmu.Lock()
+	mm.node = nd
+	mm.nmu.Unlock()
+
+	if mm.history.Record(nd.Meta, time.Now()) {
+		mm.pp.SetMeta(nd.Meta)
+	}
+}
+
+func (mm *meterMaid) MetaHistory() *node.MetaHistory {
+	return mm.history
+}
+
 func (mm *meterMaid) Containers() storage.Containers {
 	return mm.cstore
 }
 
+func (mm *meterMaid) Series() storage.Series {
+	return mm.series
+}
+
+func (mm *meterMaid) ExchangeRates() *pricing.ExchangeRates {
+	return mm.rates
+}
+
+func (mm *meterMaid) Prices() *pricing.Pricer {
+	return mm.pp
+}
+
 func (mm *meterMaid) PriceReport(start, end time.Time) (*pricing.Report, error) {
 	history, err := mm.pp.History(start, end)
 	// history, err := mm.priceHistory(start, end)
 	if err == nil {
 		// per, _ := time.ParseDuration("1h")
-		return pricing.NewReport(history), nil
+		report := pricing.NewReport(history, mm.pp.Sources(history))
+		report.SystemOverhead = mm.systemOverhead(history)
+		report.Storage, report.UnallocatedStorage = mm.storagePrice(start, end)
+		est := mm.nodeEnergy(start, end)
+		report.EnergyKWh, report.CarbonCO2e = est.KWh, est.CO2e
+		return report, nil
 	}
 	return nil, err
 }
@@ -70,14 +193,36 @@ func (mm *meterMaid) PriceReport(start, end time.Time) (*pricing.Report, error)
 func (mm *meterMaid) run(updates <-chan types.Container) {
 	// This loop will exit once the collector closes the above channel
 	// If select is used then the validity of the read must be checked.
-	var err error
+	var (
+		sources pricing.Sources
+		err     error
+	)
 	for c := range updates {
-		c.UnitsBurned, err = mm.computeContainerPrice(c)
+		if c.CPU != nil {
+			frac, source := mm.Node().CPUFraction(c.CPU)
+			c.CPUShares = int64(frac * float64(mm.Node().CPUShares))
+			c.CPUSource = source
+		}
+		c.Tags = mm.history.At(time.Unix(0, c.Create))
+		c.Currency = pricing.BaseCurrency
+		c.UnitsBurned, sources, err = mm.computeContainerPrice(c)
 		if err != nil {
 			mm.log.Info("failed to compute price", zap.Error(err))
 		}
+		c.CostSources = sources.Providers()
+		c.CostConfidence = sources.Confidence().String()
+
+		c.StorageUnitsBurned = mm.computeStoragePrice(c)
+		c.UnitsBurned += c.StorageUnitsBurned
+
+		c.NetworkUnitsBurned = mm.computeNetworkPrice(c)
+		c.UnitsBurned += c.NetworkUnitsBurned
+
+		est := mm.computeEnergy(c)
+		c.EnergyKWh, c.CarbonCO2e = est.KWh, est.CO2e
 
 		mm.cstore.Set(c)
+		mm.appendUnits(c)
 		mm.log.Info("update",
 			zap.String("id", c.ID),
 			zap.Duration("runtime", c.RunTime()),
@@ -96,14 +241,26 @@ func (mm *meterMaid) run(updates <-chan types.Container) {
 // 	return d, err
 // }
 
+// systemOverhead returns the price of the node capacity reserved for the
+// system.  Reserved cpu and memory are weighted as for containers without
+// block io
+func (mm *meterMaid) systemOverhead(prices tsdb.DataPoints) float64 {
+	rCPU, rMem := mm.Node().ReservedPercent()
+	if rCPU == 0 && rMem == 0 {
+		return 0
+	}
+	scale := mm.cpuWeight + mm.memWeight
+	return prices.Scale((mm.cpuWeight*rCPU + mm.memWeight*rMem) / scale).SumPerHour()
+}
+
 func (mm *meterMaid) utilizationPercent(c types.Container) (cpu float64, mem float64) {
-	cpu = mm.node.CPUPercent(uint64(c.CPUShares))
+	cpu = mm.Node().CPUPercent(uint64(c.CPUShares))
 	if cpu == 0 {
 		// Full utilization if no cpu set
 		cpu = 1
 	}
 
-	mem = mm.node.MemoryPercent(uint64(c.Memory))
+	mem = mm.Node().MemoryPercent(uint64(c.Memory))
 	if mem == 0 {
 		// Full utilization if no mem set
 		mem = 1
@@ -113,34 +270,40 @@ func (mm *meterMaid) utilizationPercent(c types.Container) (cpu float64, mem flo
 }
 
 // computeContainerPrice computes the price of the container using the percent of the total
-// price for the node
-func (mm *meterMaid) computeContainerPrice(update types.Container) (float64, error) {
+// price for the node.  Block io is included when enabled and known for the
+// container, otherwise the cpu and memory weights are scaled up to cover the
+// whole node.  It also returns the provenance of the prices used.
+func (mm *meterMaid) computeContainerPrice(update types.Container) (float64, pricing.Sources, error) {
 	var (
 		rCPU, rMem = mm.utilizationPercent(update)
 		start      = time.Unix(0, update.Create)
-		end        time.Time
+		end        = time.Unix(0, allocEnd(update))
 	)
 
-	if update.Destroy > 0 {
-		end = time.Unix(0, update.Destroy)
-	} else if update.Stop > 0 {
-		end = time.Unix(0, update.Stop)
-	} else {
-		end = time.Now()
-	}
-
 	prices, err := mm.pp.History(start, end)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	if len(prices) > 0 {
-		cprices := prices.Scale(mm.cpuWeight * rCPU)
-		mprices := prices.Scale(mm.memWeight * rMem)
-		return cprices.SumPerHour() + mprices.SumPerHour(), nil
+		var (
+			cpuWeight, memWeight = mm.cpuWeight, mm.memWeight
+			total                float64
+		)
+		if rIO, ok := mm.ioPercent(update); ok && mm.ioWeight > 0 {
+			total = prices.Scale(mm.ioWeight * rIO).SumPerHour()
+		} else {
+			scale := cpuWeight + memWeight
+			cpuWeight, memWeight = cpuWeight/scale, memWeight/scale
+		}
+
+		cprices := prices.Scale(cpuWeight * rCPU)
+		mprices := prices.Scale(memWeight * rMem)
+		total += cprices.SumPerHour() + mprices.SumPerHour()
+		return total, mm.pp.Sources(prices), nil
 	}
 
-	return 0, errors.New("no price history")
+	return 0, nil, errors.New("no price history")
 }
 
 // end defines how long the last price should be applied for
@@ -0,0 +1,21 @@
+package metermaid
+
+import (
+	"github.com/euforia/metermaid/types"
+)
+
+// EgressClassLabel is the container label declaring where most of its
+// traffic is sent i.e. inter-az, inter-region or internet, or its price per
+// GB e.g. 0 for traffic that stays in the zone
+const EgressClassLabel = "metermaid.egress"
+
+// computeNetworkPrice computes the price of the data sent by the container
+// using the egress class declared in its labels or the default class of the
+// rates as the destination of the traffic is not known
+func (mm *meterMaid) computeNetworkPrice(c types.Container) float64 {
+	if mm.egress == nil {
+		return 0
+	}
+	tx := mm.counterIncrease(c.ID, MetricNetTxBytes)
+	return mm.egress.Egress(c.Labels[EgressClassLabel], tx)
+}
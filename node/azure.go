@@ -0,0 +1,89 @@
+package node
+
+import (
+	"strings"
+
+	"github.com/euforia/metermaid/types"
+)
+
+// DefaultAzureEndpoint is the address of the azure instance metadata
+// service
+const DefaultAzureEndpoint = "http://169.254.169.254"
+
+// azureInstancePath is the versioned path of the instance metadata
+const azureInstancePath = "/metadata/instance?api-version=2021-02-01"
+
+// azureInstance is the subset of the instance metadata used
+type azureInstance struct {
+	Compute struct {
+		Location string `json:"location"`
+		// Availability zone number if the vm is zonal
+		Zone   string `json:"zone"`
+		VMSize string `json:"vmSize"`
+		VMID   string `json:"vmId"`
+		// Regular, Low or Spot
+		Priority string `json:"priority"`
+		OSType   string `json:"osType"`
+		TagsList []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"tagsList"`
+	} `json:"compute"`
+}
+
+// AzureNodeMeta provides node metadata from the azure instance metadata
+// service and the vm tags
+type AzureNodeMeta struct {
+	imds imdsClient
+}
+
+// NewAzureNodeMeta returns a new AzureNodeMeta using the metadata service
+// at the endpoint or DefaultAzureEndpoint if empty
+func NewAzureNodeMeta(endpoint string) *AzureNodeMeta {
+	if endpoint == "" {
+		endpoint = DefaultAzureEndpoint
+	}
+	return &AzureNodeMeta{imds: imdsClient{endpoint: endpoint, header: "Metadata", value: "true"}}
+}
+
+// Cloud satisfies the NodeMeta interface
+func (nodemeta *AzureNodeMeta) Cloud() string {
+	return "azure"
+}
+
+// Detect satisfies the NodeMeta interface.  Only azure serves the instance
+// compute metadata
+func (nodemeta *AzureNodeMeta) Detect() bool {
+	var inst azureInstance
+	_, err := nodemeta.imds.get(azureInstancePath, detectTimeout, &inst)
+	return err == nil && inst.Compute.VMSize != ""
+}
+
+// Meta satisfies the NodeMeta interface.  The OS is included as it is
+// priced differently
+func (nodemeta *AzureNodeMeta) Meta() (types.Meta, error) {
+	var inst azureInstance
+	if _, err := nodemeta.imds.get(azureInstancePath, metadataTimeout, &inst); err != nil {
+		return nil, err
+	}
+
+	c := inst.Compute
+	meta := make(types.Meta, len(c.TagsList)+7)
+	for _, tag := range c.TagsList {
+		meta[tag.Name] = tag.Value
+	}
+	meta[CloudTag] = nodemeta.Cloud()
+	meta[InstanceIDTag] = c.VMID
+	meta[InstanceTypeTag] = c.VMSize
+	meta[RegionTag] = c.Location
+	meta["OS"] = c.OSType
+	// Zones are numbered within the region e.g. eastus-1
+	if c.Zone != "" {
+		meta[ZoneTag] = c.Location + "-" + c.Zone
+	}
+	meta[LifecycleTag] = LifecycleOnDemand
+	if strings.EqualFold(c.Priority, "spot") || strings.EqualFold(c.Priority, "low") {
+		meta[LifecycleTag] = LifecycleSpot
+	}
+	return meta, nil
+}
@@ -0,0 +1,108 @@
+package node
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/euforia/metermaid/types"
+)
+
+// Parts of a cpu reservation a fraction was computed from
+const (
+	CPUSourceNanoCPUs = "nanocpus"
+	CPUSourceQuota    = "quota"
+	CPUSourceCpuset   = "cpuset"
+	CPUSourceShares   = "shares"
+	// Nothing is reserved so the whole node is used
+	CPUSourceNone = "none"
+)
+
+// defaultCPUPeriod is the CFS period in microseconds used when only a quota
+// is set
+const defaultCPUPeriod = 100000
+
+// sharesPerCPU is the weight docker gives a container by default and the
+// shares kubernetes assigns per requested cpu
+const sharesPerCPU = 1024
+
+// NumCPU returns the number of logical cpus of the node falling back to
+// those of the local host
+func (n *Node) NumCPU() int {
+	if n.CPUs > 0 {
+		return int(n.CPUs)
+	}
+	return runtime.NumCPU()
+}
+
+// CPUFraction returns the fraction of the node cpu capacity reserved by the
+// container and the part of the reservation used.  The first set of the
+// following is used:
+//
+//  1. NanoCPUs as a hard limit in cpus
+//  2. Quota over Period as a hard limit in cpus
+//  3. Cpuset as the number of pinned cpus
+//  4. Shares as cpus at 1024 shares per cpu
+//
+// A cpuset also caps the others as the container cannot use more than the
+// cpus it is pinned to.  The fraction is at most 1 and is 1 if nothing is
+// reserved
+func (n *Node) CPUFraction(res *types.CPUReservation) (float64, string) {
+	if res == nil {
+		return 1, CPUSourceNone
+	}
+
+	var (
+		cpus   float64
+		source string
+		pinned = cpusetSize(res.Cpuset)
+	)
+	switch {
+	case res.NanoCPUs > 0:
+		cpus, source = float64(res.NanoCPUs)/1e9, CPUSourceNanoCPUs
+	case res.Quota > 0:
+		period := res.Period
+		if period <= 0 {
+			period = defaultCPUPeriod
+		}
+		cpus, source = float64(res.Quota)/float64(period), CPUSourceQuota
+	case pinned > 0:
+		cpus, source = float64(pinned), CPUSourceCpuset
+	case res.Shares > 0:
+		cpus, source = float64(res.Shares)/sharesPerCPU, CPUSourceShares
+	default:
+		return 1, CPUSourceNone
+	}
+
+	if pinned > 0 && cpus > float64(pinned) {
+		cpus = float64(pinned)
+	}
+	if frac := cpus / float64(n.NumCPU()); frac < 1 {
+		return frac, source
+	}
+	return 1, source
+}
+
+// cpusetSize returns the number of cpus in a cpuset list e.g. 0-3,6 or 0 if
+// it is empty or invalid
+func cpusetSize(cpuset string) int {
+	if cpuset == "" {
+		return 0
+	}
+	var n int
+	for _, part := range strings.Split(cpuset, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		lo, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return 0
+		}
+		hi := lo
+		if len(bounds) == 2 {
+			if hi, err = strconv.Atoi(bounds[1]); err != nil || hi < lo {
+				return 0
+			}
+		}
+		n += hi - lo + 1
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+package node
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/euforia/metermaid/types"
+)
+
+// DefaultGCEEndpoint is the address of the gce metadata server
+const DefaultGCEEndpoint = "http://metadata.google.internal"
+
+// gceInstance is the subset of the recursive instance metadata used
+type gceInstance struct {
+	ID uint64 `json:"id"`
+	// e.g. projects/123/machineTypes/n2-standard-4
+	MachineType string `json:"machineType"`
+	// e.g. projects/123/zones/us-central1-a
+	Zone       string `json:"zone"`
+	Scheduling struct {
+		Preemptible       string `json:"preemptible"`
+		ProvisioningModel string `json:"provisioningModel"`
+	} `json:"scheduling"`
+}
+
+// GCENodeMeta provides node metadata from the gce metadata server
+type GCENodeMeta struct {
+	imds imdsClient
+}
+
+// NewGCENodeMeta returns a new GCENodeMeta using the metadata server at the
+// endpoint or DefaultGCEEndpoint if empty
+func NewGCENodeMeta(endpoint string) *GCENodeMeta {
+	if endpoint == "" {
+		endpoint = DefaultGCEEndpoint
+	}
+	return &GCENodeMeta{imds: imdsClient{endpoint: endpoint, header: "Metadata-Flavor", value: "Google"}}
+}
+
+// Cloud satisfies the NodeMeta interface
+func (nodemeta *GCENodeMeta) Cloud() string {
+	return "gcp"
+}
+
+// Detect satisfies the NodeMeta interface.  The server identifies itself
+// with the flavor header
+func (nodemeta *GCENodeMeta) Detect() bool {
+	header, err := nodemeta.imds.get("/computeMetadata/v1/", detectTimeout, nil)
+	return err == nil && header.Get("Metadata-Flavor") == "Google"
+}
+
+// Meta satisfies the NodeMeta interface.  Instance attributes are not
+// included as they may hold secrets such as startup scripts
+func (nodemeta *GCENodeMeta) Meta() (types.Meta, error) {
+	var inst gceInstance
+	if _, err := nodemeta.imds.get("/computeMetadata/v1/instance/?recursive=true", metadataTimeout, &inst); err != nil {
+		return nil, err
+	}
+
+	zone := lastSegment(inst.Zone)
+	meta := types.Meta{
+		CloudTag:        nodemeta.Cloud(),
+		InstanceIDTag:   strconv.FormatUint(inst.ID, 10),
+		InstanceTypeTag: lastSegment(inst.MachineType),
+		ZoneTag:         zone,
+		LifecycleTag:    LifecycleOnDemand,
+	}
+	// The region is the zone without its suffix e.g. us-central1
+	if i := strings.LastIndexByte(zone, '-'); i > 0 {
+		meta[RegionTag] = zone[:i]
+	}
+	if strings.EqualFold(inst.Scheduling.Preemptible, "true") ||
+		strings.EqualFold(inst.Scheduling.ProvisioningModel, "spot") {
+		meta[LifecycleTag] = LifecycleSpot
+	}
+	return meta, nil
+}
+
+// lastSegment returns the part of the path after the last slash
+func lastSegment(path string) string {
+	return path[strings.LastIndexByte(path, '/')+1:]
+}
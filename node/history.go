@@ -0,0 +1,83 @@
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/euforia/metermaid/types"
+)
+
+// MetaChange is the metadata of a node from the given time
+type MetaChange struct {
+	Time time.Time
+	Meta types.Meta
+}
+
+// MetaHistory holds the changes to the metadata of a node so costs can be
+// attributed to the tags in effect at the time
+type MetaHistory struct {
+	mu      sync.RWMutex
+	changes []MetaChange
+}
+
+// NewMetaHistory returns a new MetaHistory starting with the given meta
+func NewMetaHistory(meta types.Meta, at time.Time) *MetaHistory {
+	h := &MetaHistory{}
+	h.Record(meta, at)
+	return h
+}
+
+// Record adds the meta as of the given time returning false if it is the
+// same as the meta in effect at that time.  The meta is copied
+func (h *MetaHistory) Record(meta types.Meta, at time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := h.index(at)
+	if i >= 0 && h.changes[i].Meta.Equal(meta) {
+		return false
+	}
+
+	cp := make(types.Meta, len(meta))
+	for k, v := range meta {
+		cp[k] = v
+	}
+	change := MetaChange{Time: at, Meta: cp}
+	h.changes = append(h.changes, MetaChange{})
+	copy(h.changes[i+2:], h.changes[i+1:])
+	h.changes[i+1] = change
+	return true
+}
+
+// At returns the meta in effect at the given time.  The earliest known meta
+// is returned for times before the first change.  It must not be modified
+func (h *MetaHistory) At(at time.Time) types.Meta {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.changes) == 0 {
+		return nil
+	}
+	if i := h.index(at); i >= 0 {
+		return h.changes[i].Meta
+	}
+	return h.changes[0].Meta
+}
+
+// Changes returns the changes in time order
+func (h *MetaHistory) Changes() []MetaChange {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	out := make([]MetaChange, len(h.changes))
+	copy(out, h.changes)
+	return out
+}
+
+// index returns the index of the change in effect at the given time or -1
+func (h *MetaHistory) index(at time.Time) int {
+	return sort.Search(len(h.changes), func(i int) bool {
+		return h.changes[i].Time.After(at)
+	}) - 1
+}
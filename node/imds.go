@@ -0,0 +1,43 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// metadataTimeout bounds reading the metadata of a detected cloud
+const metadataTimeout = 5 * time.Second
+
+// imdsClient is a client of a link local instance metadata service
+type imdsClient struct {
+	endpoint string
+	// Header required by the service
+	header, value string
+}
+
+// get decodes the json response to the path into v.  It returns the
+// response headers
+func (c *imdsClient) get(path string, timeout time.Duration, v interface{}) (http.Header, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(c.endpoint, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(c.header, c.value)
+
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return resp.Header, fmt.Errorf("metadata %s: %s", path, resp.Status)
+	}
+	if v == nil {
+		return resp.Header, nil
+	}
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
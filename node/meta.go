@@ -1,6 +1,9 @@
 package node
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -12,32 +15,117 @@ const (
 	// SpotTag is the name of the tag on an ec2 instance to determine
 	// if the instance is a spot instance
 	SpotTag = "aws:ec2spot:fleet-request-id"
+	// CloudTag is the meta key holding the name of the cloud the node runs
+	// in e.g. aws or azure
+	CloudTag = "Cloud"
+)
+
+// Meta keys set by all NodeMeta providers so pricing works the same in each
+// cloud
+const (
+	RegionTag       = "Region"
+	ZoneTag         = "Zone"
+	InstanceTypeTag = "InstanceType"
+	InstanceIDTag   = "InstanceID"
+	// LifecycleTag is LifecycleSpot for spot, preemptible and low priority
+	// instances and LifecycleOnDemand otherwise
+	LifecycleTag = "Lifecycle"
+)
+
+// Lifecycle values
+const (
+	LifecycleOnDemand = "ondemand"
+	LifecycleSpot     = "spot"
 )
 
-// AWSNodeMeta ...
+// detectTimeout bounds probing a metadata service that may not exist
+const detectTimeout = time.Second
+
+// NodeMeta provides the metadata of the node from the instance metadata
+// service of a cloud
+type NodeMeta interface {
+	// Cloud returns the value of the CloudTag e.g. aws
+	Cloud() string
+	// Detect returns true if the node runs in the cloud
+	Detect() bool
+	// Meta returns the metadata of the node with the normalized keys set.
+	// Partial metadata may be returned along with an error
+	Meta() (types.Meta, error)
+}
+
+// DefaultNodeMetas returns the providers of all supported clouds in the
+// order they are detected.  Clouds answering with a distinct header or
+// format are probed first
+func DefaultNodeMetas() []NodeMeta {
+	return []NodeMeta{NewGCENodeMeta(""), NewAzureNodeMeta(""), NewAWSNodeMeta()}
+}
+
+// DetectNodeMeta returns the first provider detecting its cloud
+func DetectNodeMeta(providers ...NodeMeta) (NodeMeta, bool) {
+	for _, p := range providers {
+		if p.Detect() {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// AWSNodeMeta provides node metadata from the ec2 metadata service and the
+// instance tags
 type AWSNodeMeta struct{}
 
-// NewAWSNodeMeta ...
+// NewAWSNodeMeta returns a new AWSNodeMeta
 func NewAWSNodeMeta() *AWSNodeMeta {
 	return &AWSNodeMeta{}
 }
 
-// Meta returns metadata for the node
-func (nodemeta *AWSNodeMeta) Meta() types.Meta {
-	meta, err := getInstanceMeta()
+// Cloud satisfies the NodeMeta interface
+func (nodemeta *AWSNodeMeta) Cloud() string {
+	return "aws"
+}
+
+// Detect satisfies the NodeMeta interface
+func (nodemeta *AWSNodeMeta) Detect() bool {
+	sess, err := session.NewSession(&aws.Config{
+		HTTPClient: &http.Client{Timeout: detectTimeout},
+		MaxRetries: aws.Int(0),
+	})
+	if err != nil {
+		return false
+	}
+	return ec2metadata.New(sess).Available()
+}
+
+// Meta satisfies the NodeMeta interface.  Instance tags are included if the
+// node may describe itself, otherwise the identity metadata is returned
+// with the error
+func (nodemeta *AWSNodeMeta) Meta() (types.Meta, error) {
+	ident, err := getInstanceMeta()
+	if err != nil {
+		return nil, err
+	}
+
+	// Instance tags are set first so they cannot override the normalized
+	// keys
+	meta := make(types.Meta, len(ident)+1)
+	spot := false
+	reserve, err := describeInstance(ident[RegionTag], ident[InstanceIDTag])
 	if err == nil {
-		var reserve *ec2.Reservation
-		reserve, err = describeInstance(meta["Region"], meta["InstanceID"])
-		if err == nil {
-			instance := reserve.Instances[0]
-			//"InstanceLifecycle": "spot",
-			for _, tag := range instance.Tags {
-				meta[*tag.Key] = *tag.Value
-			}
+		instance := reserve.Instances[0]
+		for _, tag := range instance.Tags {
+			meta[*tag.Key] = *tag.Value
 		}
+		_, fleet := meta[SpotTag]
+		spot = fleet || aws.StringValue(instance.InstanceLifecycle) == "spot"
 	}
-
-	return meta
+	for k, v := range ident {
+		meta[k] = v
+	}
+	meta[LifecycleTag] = LifecycleOnDemand
+	if spot {
+		meta[LifecycleTag] = LifecycleSpot
+	}
+	return meta, err
 }
 
 func getInstanceMeta() (map[string]string, error) {
@@ -47,10 +135,12 @@ func getInstanceMeta() (map[string]string, error) {
 		var ident ec2metadata.EC2InstanceIdentityDocument
 		if ident, err = svc.GetInstanceIdentityDocument(); err == nil {
 			meta := make(map[string]string)
-			meta["InstanceType"] = ident.InstanceType
-			meta["InstanceID"] = ident.InstanceID
+			meta[CloudTag] = "aws"
+			meta[InstanceTypeTag] = ident.InstanceType
+			meta[InstanceIDTag] = ident.InstanceID
 			meta["AvailabilityZone"] = ident.AvailabilityZone
-			meta["Region"] = ident.Region
+			meta[ZoneTag] = ident.AvailabilityZone
+			meta[RegionTag] = ident.Region
 			return meta, nil
 		}
 	}
@@ -70,8 +160,13 @@ func describeInstance(region, instanceID string) (*ec2.Reservation, error) {
 	return nil, err
 }
 
-// Metadata returns metadata for the node.  In the future this will be dynamic
-func Metadata() map[string]string {
-	nm := &AWSNodeMeta{}
+// Metadata returns the metadata of the node from the detected cloud.  It is
+// nil if no cloud is detected e.g. in development.  Partial metadata may be
+// returned along with an error
+func Metadata() (types.Meta, error) {
+	nm, ok := DetectNodeMeta(DefaultNodeMetas()...)
+	if !ok {
+		return nil, nil
+	}
 	return nm.Meta()
 }
@@ -3,6 +3,7 @@ package node
 import (
 	"bytes"
 	"encoding/binary"
+	"math"
 	"strings"
 
 	"github.com/euforia/metermaid/fl"
@@ -39,6 +40,27 @@ type Platform struct {
 	Version string
 }
 
+// MetaPrefix addresses a meta key explicitly in queries e.g.
+// Meta.Name=web-1 where Name would otherwise be the node name
+const MetaPrefix = "Meta."
+
+// Schema is the queryable fields of a Node.  Any other name is a meta key
+var Schema = &fl.Schema{
+	Fields: map[string]fl.FieldType{
+		"Name":      fl.FieldString,
+		"Address":   fl.FieldString,
+		"CPUShares": fl.FieldInt,
+		"Memory":    fl.FieldInt,
+	},
+	DynamicPrefix: MetaPrefix,
+	Dynamic:       fl.DynamicAny,
+	Key:           "Name",
+}
+
+func init() {
+	fl.RegisterSchema("node", Schema)
+}
+
 // Node holds information about a given matchine
 type Node struct {
 	// Node name
@@ -47,8 +69,14 @@ type Node struct {
 	Address string
 	// Total cpu shares in MHz
 	CPUShares uint64
+	// Number of logical cpus.  This is local to the node and not gossiped
+	CPUs uint64 `json:",omitempty"`
 	// Total memory in bytes
 	Memory uint64
+	// Cpu in MHz and memory in bytes reserved for the os, runtime and
+	// agents.  These are local to the node and not gossiped
+	ReservedCPUShares uint64 `json:",omitempty"`
+	ReservedMemory    uint64 `json:",omitempty"`
 	// Time the system booted
 	BootTime uint64
 	// OS and harware info
@@ -56,11 +84,14 @@ type Node struct {
 	// Arbitrary node metadata including things like instance type. These
 	// are used for grouping and aggregation queries.
 	Meta types.Meta
+	// Attached block storage volumes. These are local to the node and not
+	// gossiped.
+	Volumes []Volume `json:",omitempty"`
 }
 
 func (n *Node) Match(query fl.Query) bool {
 	for k, filters := range query {
-		if n.MatchMeta(k, filters...) {
+		if n.MatchField(k, filters...) {
 			continue
 		}
 		return false
@@ -68,14 +99,75 @@ func (n *Node) Match(query fl.Query) bool {
 	return true
 }
 
-func (n *Node) MatchMeta(name string, filters ...fl.Filter) bool {
-	val, ok := n.Meta[name]
-	if !ok {
-		return false
+// MatchField returns true if the named field matches all filters.  Unknown
+// fields are matched against the node meta as are node fields that do not
+// match but are also set in the meta
+func (n *Node) MatchField(name string, filters ...fl.Filter) bool {
+	var match bool
+	switch name {
+	case "Name":
+		match = matchStrings(n.Name, filters)
+	case "Address":
+		match = matchStrings(n.Address, filters)
+	case "CPUShares":
+		match = matchUint64(n.CPUShares, filters)
+	case "Memory":
+		match = matchUint64(n.Memory, filters)
+	default:
+		return n.MatchMeta(strings.TrimPrefix(name, MetaPrefix), filters...)
+	}
+	if _, ok := n.Meta[name]; ok && !match {
+		return n.MatchMeta(name, filters...)
 	}
+	return match
+}
 
+// FieldValue satisfies the fl.Valuer interface.  Unknown fields are meta
+func (n *Node) FieldValue(name string) interface{} {
+	switch name {
+	case "Name":
+		return n.Name
+	case "Address":
+		return n.Address
+	case "CPUShares":
+		return int64(n.CPUShares)
+	case "Memory":
+		return int64(n.Memory)
+	}
+	if val, ok := n.Meta[strings.TrimPrefix(name, MetaPrefix)]; ok {
+		return val
+	}
+	return nil
+}
+
+func matchStrings(val string, filters []fl.Filter) bool {
+	for _, filter := range filters {
+		if !fl.MatchString(val, filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchUint64(val uint64, filters []fl.Filter) bool {
 	for _, filter := range filters {
-		if fl.MatchString(val, filter) {
+		if !fl.MatchInt64(int64(val), filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Node) MatchMeta(name string, filters ...fl.Filter) bool {
+	val, ok := n.Meta[name]
+	for _, filter := range filters {
+		if match, isExists := fl.MatchExists(ok, filter); isExists {
+			if match {
+				continue
+			}
+			return false
+		}
+		if ok && fl.MatchString(val, filter) {
 			continue
 		}
 		return false
@@ -105,16 +197,47 @@ func (n *Node) MarshalMeta() []byte {
 	return data
 }
 
+// AllocatableCPUShares returns the cpu MHz available to containers
+func (n *Node) AllocatableCPUShares() uint64 {
+	return allocatable(n.CPUShares, n.ReservedCPUShares)
+}
+
+// AllocatableMemory returns the memory bytes available to containers
+func (n *Node) AllocatableMemory() uint64 {
+	return allocatable(n.Memory, n.ReservedMemory)
+}
+
+// allocatable returns the total less the reserved ignoring a reservation
+// of the whole total
+func allocatable(total, reserved uint64) uint64 {
+	if reserved >= total {
+		return total
+	}
+	return total - reserved
+}
+
 // CPUPercent returns the percent ratio of the given shares relative to the
-// node
+// allocatable cpu of the node, at most 1
 func (n *Node) CPUPercent(shares uint64) float64 {
-	return float64(shares) / float64(n.CPUShares)
+	return math.Min(1, float64(shares)/float64(n.AllocatableCPUShares()))
 }
 
 // MemoryPercent returns the percent ratio of the given mem relative to the
-// node
+// allocatable memory of the node, at most 1
 func (n *Node) MemoryPercent(mem uint64) float64 {
-	return float64(mem) / float64(n.Memory)
+	return math.Min(1, float64(mem)/float64(n.AllocatableMemory()))
+}
+
+// ReservedPercent returns the percent ratios of the cpu and memory reserved
+// for the system relative to the node
+func (n *Node) ReservedPercent() (cpu, mem float64) {
+	if n.CPUShares > 0 {
+		cpu = 1 - float64(n.AllocatableCPUShares())/float64(n.CPUShares)
+	}
+	if n.Memory > 0 {
+		mem = 1 - float64(n.AllocatableMemory())/float64(n.Memory)
+	}
+	return
 }
 
 func (n *Node) UnmarshalMeta(meta []byte) {
@@ -145,13 +268,18 @@ func (n *Node) UnmarshalMeta(meta []byte) {
 func New() *Node {
 	cpus, _ := cpu.Info()
 	// Get total for all cpus and cores
-	var mhz float64
+	var (
+		mhz   float64
+		cores uint64
+	)
 	for _, c := range cpus {
 		mhz += c.Mhz * float64(c.Cores)
+		cores += uint64(c.Cores)
 	}
 
 	node := &Node{
 		CPUShares: uint64(mhz),
+		CPUs:      cores,
 	}
 	// Total memory for node
 	m, _ := mem.VirtualMemory()
@@ -1,8 +1,17 @@
 package node
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -11,3 +20,229 @@ func Test_Node(t *testing.T) {
 	assert.NotEmpty(t, node.CPUShares)
 	assert.NotEmpty(t, node.Memory)
 }
+
+func Test_Node_MatchField(t *testing.T) {
+	n := &Node{
+		Name:      "host-1",
+		CPUShares: 4000,
+		Meta:      types.Meta{"Name": "web-node", "team": "infra"},
+	}
+	eq := func(v string) fl.Filter { return fl.Filter{Operator: fl.NoOp, Values: []string{v}} }
+
+	assert.True(t, n.MatchField("Name", eq("host-1")))
+	// Node fields fall back to the meta
+	assert.True(t, n.MatchField("Name", eq("web-node")))
+	assert.False(t, n.MatchField("Name", eq("other")))
+	assert.True(t, n.MatchField("CPUShares", eq("4000")))
+	assert.False(t, n.MatchField("CPUShares", eq("100")))
+	assert.True(t, n.MatchField("team", eq("infra")))
+	assert.True(t, n.MatchField(MetaPrefix+"team", eq("infra")))
+}
+
+func Test_Node_VolumeFor(t *testing.T) {
+	node := &Node{Volumes: []Volume{
+		{ID: "vol-root", MountPoint: "/"},
+		{ID: "vol-data", MountPoint: "/data"},
+		{ID: "vol-unmounted"},
+	}}
+
+	vol, ok := node.VolumeFor("/data/app")
+	assert.True(t, ok)
+	assert.Equal(t, "vol-data", vol.ID)
+
+	vol, ok = node.VolumeFor("/database")
+	assert.True(t, ok)
+	assert.Equal(t, "vol-root", vol.ID)
+
+	_, ok = (&Node{}).VolumeFor("/data")
+	assert.False(t, ok)
+}
+
+func Test_isDeviceOrPartition(t *testing.T) {
+	assert.True(t, isDeviceOrPartition("/dev/nvme1n1", "/dev/nvme1n1"))
+	assert.True(t, isDeviceOrPartition("/dev/nvme1n1p12", "/dev/nvme1n1"))
+	assert.False(t, isDeviceOrPartition("/dev/nvme1n10", "/dev/nvme1n1"))
+	assert.False(t, isDeviceOrPartition("/dev/nvme1n1p", "/dev/nvme1n1"))
+	assert.False(t, isDeviceOrPartition("/dev/nvme1n1px", "/dev/nvme1n1"))
+}
+
+func Test_Node_CPUFraction(t *testing.T) {
+	node := &Node{CPUs: 8}
+	for _, tc := range []struct {
+		res    *types.CPUReservation
+		frac   float64
+		source string
+	}{
+		{nil, 1, CPUSourceNone},
+		{&types.CPUReservation{}, 1, CPUSourceNone},
+		{&types.CPUReservation{NanoCPUs: 2e9, Quota: 50000, Shares: 512}, 0.25, CPUSourceNanoCPUs},
+		{&types.CPUReservation{Quota: 50000, Period: 100000, Shares: 512}, 0.0625, CPUSourceQuota},
+		{&types.CPUReservation{Quota: 400000}, 0.5, CPUSourceQuota},
+		{&types.CPUReservation{Cpuset: "0-1,6", Shares: 512}, 0.375, CPUSourceCpuset},
+		{&types.CPUReservation{Shares: 2048}, 0.25, CPUSourceShares},
+		// Pinning caps a larger limit
+		{&types.CPUReservation{NanoCPUs: 4e9, Cpuset: "2"}, 0.125, CPUSourceNanoCPUs},
+		{&types.CPUReservation{NanoCPUs: 16e9}, 1, CPUSourceNanoCPUs},
+		{&types.CPUReservation{Cpuset: "3-1", Shares: 1024}, 0.125, CPUSourceShares},
+	} {
+		frac, source := node.CPUFraction(tc.res)
+		assert.InDelta(t, tc.frac, frac, 1e-9)
+		assert.Equal(t, tc.source, source)
+	}
+}
+
+func Test_Node_Allocatable(t *testing.T) {
+	node := &Node{CPUShares: 8000, Memory: 16 << 30, ReservedCPUShares: 1000, ReservedMemory: 4 << 30}
+	assert.Equal(t, uint64(7000), node.AllocatableCPUShares())
+	assert.Equal(t, uint64(12<<30), node.AllocatableMemory())
+	assert.InDelta(t, 0.5, node.CPUPercent(3500), 1e-9)
+	assert.InDelta(t, 0.25, node.MemoryPercent(3<<30), 1e-9)
+	// A container may reserve more than is allocatable
+	assert.Equal(t, 1.0, node.CPUPercent(8000))
+
+	cpu, mem := node.ReservedPercent()
+	assert.InDelta(t, 0.125, cpu, 1e-9)
+	assert.InDelta(t, 0.25, mem, 1e-9)
+
+	node.ReservedCPUShares = 9000
+	assert.Equal(t, uint64(8000), node.AllocatableCPUShares())
+}
+
+func Test_Node_MeasureReserved(t *testing.T) {
+	root := t.TempDir()
+	// v2 system slice with a docker container and v1 user slice
+	scope := filepath.Join(root, "system.slice", "docker-0123abcd.scope")
+	os.MkdirAll(scope, 0755)
+	os.MkdirAll(filepath.Join(root, "cpuacct", "user.slice"), 0755)
+	os.MkdirAll(filepath.Join(root, "memory", "user.slice"), 0755)
+	stat := filepath.Join(root, "system.slice", "cpu.stat")
+	ioutil.WriteFile(stat, []byte("usage_usec 1000000\nuser_usec 1\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "system.slice", "memory.current"), []byte("5242880\n"), 0644)
+	ioutil.WriteFile(filepath.Join(scope, "cpu.stat"), []byte("usage_usec 500000\n"), 0644)
+	ioutil.WriteFile(filepath.Join(scope, "memory.current"), []byte("4194304\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "cpuacct", "user.slice", "cpuacct.usage"), []byte("5"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "memory", "user.slice", "memory.usage_in_bytes"), []byte("2097152"), 0644)
+
+	node := &Node{CPUShares: 8000, CPUs: 4}
+	err := node.measureReserved(root, DefaultSystemSlices, func() time.Duration {
+		// Three cpu seconds used by the slice of which one by the container
+		ioutil.WriteFile(stat, []byte("usage_usec 4000000\n"), 0644)
+		ioutil.WriteFile(filepath.Join(scope, "cpu.stat"), []byte("usage_usec 1500000\n"), 0644)
+		return time.Second
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3<<20), node.ReservedMemory)
+	// Two cpus at 2000 MHz each
+	assert.Equal(t, uint64(4000), node.ReservedCPUShares)
+
+	err = node.MeasureReserved(t.TempDir(), DefaultSystemSlices, 0)
+	assert.NotNil(t, err)
+}
+
+func Test_MetaHistory(t *testing.T) {
+	t0 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	h := NewMetaHistory(types.Meta{"Region": "us-east-1"}, t0)
+
+	assert.False(t, h.Record(types.Meta{"Region": "us-east-1"}, t0.Add(time.Hour)))
+	tagged := types.Meta{"Region": "us-east-1", "team": "infra"}
+	assert.True(t, h.Record(tagged, t0.Add(2*time.Hour)))
+	// Recorded meta is copied
+	tagged["team"] = "web"
+
+	assert.Equal(t, "", h.At(t0.Add(-time.Hour))["team"])
+	assert.Equal(t, "", h.At(t0.Add(time.Hour))["team"])
+	assert.Equal(t, "infra", h.At(t0.Add(2*time.Hour))["team"])
+
+	// Out of order changes are placed by time
+	assert.True(t, h.Record(types.Meta{"Region": "us-east-1", "team": "data"}, t0.Add(time.Hour)))
+	changes := h.Changes()
+	assert.Equal(t, 3, len(changes))
+	assert.Equal(t, "data", h.At(t0.Add(90*time.Minute))["team"])
+	assert.Equal(t, "infra", h.At(t0.Add(3*time.Hour))["team"])
+}
+
+func gceStandIn(scheduling string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(403)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+		switch r.URL.Path {
+		case "/computeMetadata/v1/":
+			fmt.Fprintln(w, "instance/")
+		case "/computeMetadata/v1/instance/":
+			fmt.Fprintf(w, `{"id":4520031799277581759,"machineType":"projects/123/machineTypes/n2-standard-4",
+				"zone":"projects/123/zones/us-central1-a","scheduling":%s}`, scheduling)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+}
+
+func azureStandIn() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Path != "/metadata/instance" {
+			w.WriteHeader(400)
+			return
+		}
+		fmt.Fprint(w, `{"compute":{"location":"eastus","zone":"2","vmSize":"Standard_D2s_v3",
+			"vmId":"02aab8a4-74ef-476e-8182-f6d2ba4166a6","priority":"Spot","osType":"Linux",
+			"tagsList":[{"name":"team","value":"infra"},{"name":"Region","value":"bogus"}]}}`)
+	}))
+}
+
+func Test_GCENodeMeta(t *testing.T) {
+	srv := gceStandIn(`{"preemptible":"FALSE","provisioningModel":"STANDARD"}`)
+	defer srv.Close()
+
+	meta, err := NewGCENodeMeta(srv.URL).Meta()
+	assert.Nil(t, err)
+	assert.Equal(t, types.Meta{
+		CloudTag:        "gcp",
+		InstanceIDTag:   "4520031799277581759",
+		InstanceTypeTag: "n2-standard-4",
+		RegionTag:       "us-central1",
+		ZoneTag:         "us-central1-a",
+		LifecycleTag:    LifecycleOnDemand,
+	}, meta)
+
+	spot := gceStandIn(`{"preemptible":"FALSE","provisioningModel":"SPOT"}`)
+	defer spot.Close()
+	meta, err = NewGCENodeMeta(spot.URL).Meta()
+	assert.Nil(t, err)
+	assert.Equal(t, LifecycleSpot, meta[LifecycleTag])
+}
+
+func Test_AzureNodeMeta(t *testing.T) {
+	srv := azureStandIn()
+	defer srv.Close()
+
+	meta, err := NewAzureNodeMeta(srv.URL).Meta()
+	assert.Nil(t, err)
+	assert.Equal(t, "azure", meta[CloudTag])
+	assert.Equal(t, "eastus", meta[RegionTag])
+	assert.Equal(t, "eastus-2", meta[ZoneTag])
+	assert.Equal(t, "Standard_D2s_v3", meta[InstanceTypeTag])
+	assert.Equal(t, LifecycleSpot, meta[LifecycleTag])
+	assert.Equal(t, "Linux", meta["OS"])
+	assert.Equal(t, "infra", meta["team"])
+}
+
+func Test_DetectNodeMeta(t *testing.T) {
+	gce := gceStandIn(`{}`)
+	defer gce.Close()
+	azure := azureStandIn()
+	defer azure.Close()
+
+	nm, ok := DetectNodeMeta(NewAzureNodeMeta(gce.URL), NewGCENodeMeta(gce.URL))
+	assert.True(t, ok)
+	assert.Equal(t, "gcp", nm.Cloud())
+
+	nm, ok = DetectNodeMeta(NewGCENodeMeta(azure.URL), NewAzureNodeMeta(azure.URL))
+	assert.True(t, ok)
+	assert.Equal(t, "azure", nm.Cloud())
+
+	_, ok = DetectNodeMeta(NewGCENodeMeta(azure.URL))
+	assert.False(t, ok)
+}
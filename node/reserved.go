@@ -0,0 +1,165 @@
+package node
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCgroupRoot is where the cgroup hierarchy is mounted
+const DefaultCgroupRoot = "/sys/fs/cgroup"
+
+// DefaultSystemSlices are the cgroups of the os, container runtime and
+// agents on systemd hosts
+var DefaultSystemSlices = []string{"system.slice", "user.slice", "init.scope"}
+
+// ContainerScopes are the glob patterns of container cgroups nested in the
+// system slices e.g. by the systemd cgroup driver of docker.  Their usage is
+// already priced as containers and is excluded from the slices
+var ContainerScopes = []string{"docker-*.scope", "cri-containerd-*.scope", "crio-*.scope", "libpod-*.scope"}
+
+// errNoSlices is returned when none of the slices could be read
+var errNoSlices = errors.New("no system cgroup slices found")
+
+// MeasureReserved sets the reserved cpu and memory of the node to the usage
+// of the given cgroup slices less that of the containers in them.  cpu is
+// the average used over the interval and memory the usage at its end.  Both
+// cgroup v1 and v2 hierarchies are supported.  Slices that do not exist are
+// skipped
+func (n *Node) MeasureReserved(root string, slices []string, interval time.Duration) error {
+	return n.measureReserved(root, slices, func() time.Duration {
+		start := time.Now()
+		time.Sleep(interval)
+		return time.Since(start)
+	})
+}
+
+// measureReserved measures the reserved capacity over the time wait takes
+// to return.  wait returns the time elapsed
+func (n *Node) measureReserved(root string, slices []string, wait func() time.Duration) error {
+	before, err := slicesCPUUsage(root, slices)
+	if err != nil {
+		return err
+	}
+	elapsed := wait()
+	after, err := slicesCPUUsage(root, slices)
+	if err != nil {
+		return err
+	}
+
+	var used time.Duration
+	for cgroup, usage := range after {
+		// Containers started during the interval used all of theirs in it
+		used += usage - before[cgroup]
+	}
+	if used < 0 || elapsed <= 0 {
+		used = 0
+	}
+
+	var mem int64
+	for _, slice := range slices {
+		if m, err := cgroupMemoryUsage(root, slice); err == nil {
+			mem += int64(m)
+		}
+		for _, scope := range containerScopes(root, slice) {
+			if m, err := cgroupMemoryUsage(root, scope); err == nil {
+				mem -= int64(m)
+			}
+		}
+	}
+	if mem < 0 {
+		mem = 0
+	}
+
+	cpus := float64(used) / float64(elapsed)
+	n.ReservedCPUShares = uint64(cpus * float64(n.CPUShares) / float64(n.NumCPU()))
+	n.ReservedMemory = uint64(mem)
+	return nil
+}
+
+// slicesCPUUsage returns the cpu time used by each slice and, as negative
+// values, by each container in them
+func slicesCPUUsage(root string, slices []string) (map[string]time.Duration, error) {
+	usages := make(map[string]time.Duration)
+	for _, slice := range slices {
+		usage, err := cgroupCPUUsage(root, slice)
+		if err != nil {
+			continue
+		}
+		usages[slice] = usage
+		for _, scope := range containerScopes(root, slice) {
+			if usage, err := cgroupCPUUsage(root, scope); err == nil {
+				usages[scope] = -usage
+			}
+		}
+	}
+	if len(usages) == 0 {
+		return nil, errNoSlices
+	}
+	return usages, nil
+}
+
+// containerScopes returns the container cgroups nested in the slice in
+// either hierarchy
+func containerScopes(root, slice string) []string {
+	var (
+		scopes []string
+		seen   = make(map[string]bool)
+	)
+	for _, hierarchy := range []string{"", "cpuacct", "memory"} {
+		for _, pattern := range ContainerScopes {
+			matches, _ := filepath.Glob(filepath.Join(root, hierarchy, slice, pattern))
+			for _, m := range matches {
+				scope := filepath.Join(slice, filepath.Base(m))
+				if !seen[scope] {
+					seen[scope] = true
+					scopes = append(scopes, scope)
+				}
+			}
+		}
+	}
+	return scopes
+}
+
+// cgroupCPUUsage returns the cpu time used by the cgroup from the v2
+// cpu.stat or the v1 cpuacct.usage
+func cgroupCPUUsage(root, cgroup string) (time.Duration, error) {
+	fh, err := os.Open(filepath.Join(root, cgroup, "cpu.stat"))
+	if err != nil {
+		ns, err := readUint(filepath.Join(root, "cpuacct", cgroup, "cpuacct.usage"))
+		return time.Duration(ns), err
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usec, err := strconv.ParseUint(fields[1], 10, 64)
+			return time.Duration(usec) * time.Microsecond, err
+		}
+	}
+	return 0, errors.New("usage_usec not found")
+}
+
+// cgroupMemoryUsage returns the memory used by the cgroup from the v2
+// memory.current or the v1 memory.usage_in_bytes
+func cgroupMemoryUsage(root, cgroup string) (uint64, error) {
+	if mem, err := readUint(filepath.Join(root, cgroup, "memory.current")); err == nil {
+		return mem, nil
+	}
+	return readUint(filepath.Join(root, "memory", cgroup, "memory.usage_in_bytes"))
+}
+
+func readUint(path string) (uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
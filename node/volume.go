@@ -0,0 +1,186 @@
+package node
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/shirou/gopsutil/disk"
+)
+
+// Volume holds information about a block storage volume attached to the
+// node
+type Volume struct {
+	ID string
+	// Device name as known to the cloud provider e.g. /dev/sdf
+	Device string
+	// Volume type e.g. gp3 or io2
+	Type string
+	// Size in GiB
+	Size int64
+	// Provisioned IOPS
+	IOPS int64
+	// Where the volume is mounted on the node if at all
+	MountPoint string
+}
+
+// Volumes returns the ebs volumes attached to the node along with where
+// they are mounted
+func (nodemeta *AWSNodeMeta) Volumes() ([]Volume, error) {
+	meta, err := getInstanceMeta()
+	if err != nil {
+		return nil, err
+	}
+
+	reserve, err := describeInstance(meta["Region"], meta["InstanceID"])
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		instance = reserve.Instances[0]
+		devices  = make(map[string]string)
+		ids      = make([]*string, 0, len(instance.BlockDeviceMappings))
+	)
+	for _, bdm := range instance.BlockDeviceMappings {
+		if bdm.Ebs == nil {
+			continue
+		}
+		devices[*bdm.Ebs.VolumeId] = aws.StringValue(bdm.DeviceName)
+		ids = append(ids, bdm.Ebs.VolumeId)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	vols, err := describeVolumes(meta["Region"], ids)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := mountPoints()
+	out := make([]Volume, 0, len(vols))
+	for _, v := range vols {
+		vol := Volume{
+			ID:     aws.StringValue(v.VolumeId),
+			Type:   aws.StringValue(v.VolumeType),
+			Size:   aws.Int64Value(v.Size),
+			IOPS:   aws.Int64Value(v.Iops),
+			Device: devices[aws.StringValue(v.VolumeId)],
+		}
+		vol.MountPoint = findMountPoint(vol, mounts)
+		out = append(out, vol)
+	}
+	return out, nil
+}
+
+func describeVolumes(region string, ids []*string) ([]*ec2.Volume, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err == nil {
+		svc := ec2.New(sess)
+		var resp *ec2.DescribeVolumesOutput
+		resp, err = svc.DescribeVolumes(&ec2.DescribeVolumesInput{VolumeIds: ids})
+		if err == nil {
+			return resp.Volumes, nil
+		}
+	}
+	return nil, err
+}
+
+// mountPoints returns a map of device to mount point for the node
+func mountPoints() map[string]string {
+	parts, _ := disk.Partitions(false)
+	mounts := make(map[string]string, len(parts))
+	for _, p := range parts {
+		mounts[p.Device] = p.Mountpoint
+	}
+	return mounts
+}
+
+// findMountPoint returns where the volume is mounted. The kernel may name the
+// device differently from the cloud provider i.e. xvd instead of sd, or nvme
+// in which case the device serial holds the volume id.
+func findMountPoint(vol Volume, mounts map[string]string) string {
+	if vol.Device != "" {
+		dev := vol.Device
+		if !strings.HasPrefix(dev, "/dev/") {
+			dev = "/dev/" + dev
+		}
+		if mp, ok := mounts[dev]; ok {
+			return mp
+		}
+		if mp, ok := mounts[strings.Replace(dev, "/dev/sd", "/dev/xvd", 1)]; ok {
+			return mp
+		}
+	}
+
+	serial := strings.Replace(vol.ID, "-", "", 1)
+	paths, _ := filepath.Glob("/sys/block/nvme*/device/serial")
+	for _, p := range paths {
+		b, err := ioutil.ReadFile(p)
+		if err != nil || strings.TrimSpace(string(b)) != serial {
+			continue
+		}
+		name := filepath.Base(filepath.Dir(filepath.Dir(p)))
+		for dev, mp := range mounts {
+			if isDeviceOrPartition(dev, "/dev/"+name) {
+				return mp
+			}
+		}
+	}
+	return ""
+}
+
+// isDeviceOrPartition returns true if dev is the nvme device or one of its
+// partitions e.g. nvme1n1p1 but not nvme1n10
+func isDeviceOrPartition(dev, device string) bool {
+	if dev == device {
+		return true
+	}
+	part := strings.TrimPrefix(dev, device+"p")
+	if part == dev || part == "" {
+		return false
+	}
+	for _, r := range part {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// AttachedVolumes returns the volumes attached to the node
+func AttachedVolumes() []Volume {
+	nm := &AWSNodeMeta{}
+	vols, _ := nm.Volumes()
+	return vols
+}
+
+// VolumeFor returns the volume holding the given host path i.e. the volume
+// with the longest mount point prefixing the path
+func (n *Node) VolumeFor(path string) (Volume, bool) {
+	var (
+		found Volume
+		ok    bool
+	)
+	for _, v := range n.Volumes {
+		if v.MountPoint == "" || len(v.MountPoint) <= len(found.MountPoint) {
+			continue
+		}
+		if path == v.MountPoint || strings.HasPrefix(path, strings.TrimSuffix(v.MountPoint, "/")+"/") {
+			found, ok = v, true
+		}
+	}
+	return found, ok
+}
+
+// IOPS returns the total provisioned IOPS of all attached volumes
+func (n *Node) IOPS() (total int64) {
+	for _, v := range n.Volumes {
+		total += v.IOPS
+	}
+	return
+}
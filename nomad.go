@@ -0,0 +1,387 @@
+package metermaid
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/euforia/metermaid/nomad"
+	"github.com/euforia/metermaid/types"
+)
+
+// DefaultRestartGrace is how long an allocation without task containers
+// waits for them to be replaced before it is considered destroyed, when its
+// status cannot be looked up from the agent
+const DefaultRestartGrace = 5 * time.Minute
+
+// allocation is the state of a nomad allocation built from the containers
+// of its tasks
+type allocation struct {
+	alloc *nomad.Allocation
+	// Reserved resources from the agent.  Zero if it could not be reached
+	mhz, mem int64
+	// Task containers by id including those replaced by a restart
+	tasks map[string]types.Container
+	// Latest usage sample by task container id
+	samples map[string]types.ContainerStats
+	// Time the allocation was destroyed.  Zero while it may still run
+	ended int64
+	// Whether a lookup from the agent is in flight and whether the last
+	// one succeeded
+	looking, known bool
+	// Last update sent
+	sent *types.Container
+}
+
+// taskKey returns the key identifying the task of a container across
+// restarts.  The docker driver names containers after the task and alloc
+func taskKey(c types.Container) string {
+	if name := c.Labels[nomad.DockerLabelTaskName]; name != "" {
+		return name
+	}
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.ID
+}
+
+// current returns the latest container of each task
+func (a *allocation) current() map[string]types.Container {
+	latest := make(map[string]types.Container, len(a.tasks))
+	for _, t := range a.tasks {
+		key := taskKey(t)
+		if prev, ok := latest[key]; !ok || t.Create > prev.Create {
+			latest[key] = t
+		}
+	}
+	return latest
+}
+
+// running returns true if any task container has not been destroyed and
+// the time the last one was
+func (a *allocation) running() (bool, int64) {
+	var last int64
+	for _, t := range a.tasks {
+		if !t.Destroyed() {
+			return true, 0
+		}
+		if t.Destroy > last {
+			last = t.Destroy
+		}
+	}
+	return false, last
+}
+
+// container returns the allocation as a single container spanning its
+// tasks and their restarts.  It is stopped once the latest container of
+// every task is and destroyed once the allocation has ended
+func (a *allocation) container() types.Container {
+	c := types.Container{
+		ID:      a.alloc.ID,
+		Name:    a.alloc.Name,
+		Labels:  a.alloc.Labels(),
+		Destroy: a.ended,
+	}
+	for _, t := range a.tasks {
+		if c.Create == 0 || t.Create < c.Create {
+			c.Create = t.Create
+		}
+		if t.Start > 0 && (c.Start == 0 || t.Start < c.Start) {
+			c.Start = t.Start
+		}
+	}
+
+	stopped := true
+	var mhz, mem int64
+	for _, t := range a.current() {
+		if t.Stop > c.Stop {
+			c.Stop = t.Stop
+		}
+		stopped = stopped && t.Stop > 0
+		// Nomad sets the docker shares of a task to its MHz
+		if t.CPU != nil {
+			mhz += t.CPU.Shares
+		} else {
+			mhz += t.CPUShares
+		}
+		mem += t.Memory
+		c.Mounts = append(c.Mounts, t.Mounts...)
+	}
+	if !stopped {
+		c.Stop = 0
+	}
+	if c.Destroy > 0 && (c.Stop == 0 || c.Stop > c.Destroy) {
+		c.Stop = c.Destroy
+	}
+	if c.Name == "" {
+		c.Name = a.alloc.ID
+	}
+
+	// The declared resources cover all tasks even before they are created
+	c.CPUShares, c.Memory = mhz, mem
+	if a.mhz > 0 {
+		c.CPUShares = a.mhz
+	}
+	if a.mem > 0 {
+		c.Memory = a.mem
+	}
+	return c
+}
+
+// stats returns the usage of the allocation as the sum of its tasks.
+// Counters of replaced containers are kept so the totals carry across
+// restarts
+func (a *allocation) stats(ts int64) types.ContainerStats {
+	stats := types.ContainerStats{ID: a.alloc.ID, Timestamp: ts}
+	for id, s := range a.samples {
+		stats.NetRxBytes += s.NetRxBytes
+		stats.NetTxBytes += s.NetTxBytes
+		stats.BlkReadBytes += s.BlkReadBytes
+		stats.BlkWriteBytes += s.BlkWriteBytes
+		stats.BlkReadOps += s.BlkReadOps
+		stats.BlkWriteOps += s.BlkWriteOps
+		if t := a.tasks[id]; !t.Destroyed() {
+			stats.WritableLayerBytes += s.WritableLayerBytes
+		}
+	}
+	return stats
+}
+
+// allocLookup is the result of looking up an allocation from the agent
+type allocLookup struct {
+	id    string
+	alloc *nomad.Allocation
+	err   error
+}
+
+// nCollector implements the CCollector interface metering nomad
+// allocations.  Containers of nomad tasks from the underlying collector are
+// combined into one container per allocation, priced by the cpu MHz and
+// memory declared in the job.  Other containers are passed through
+type nCollector struct {
+	cc     CCollector
+	client *nomad.Client
+
+	// Allocations by id and the allocation id of each task container
+	allocs map[string]*allocation
+	tasks  map[string]string
+
+	// Results of agent lookups made off the update loop
+	lookups chan allocLookup
+	// Time to wait for replacement containers without the agent
+	restartGrace time.Duration
+
+	out   chan types.Container
+	stats chan types.ContainerStats
+	done  chan struct{}
+
+	log *zap.Logger
+}
+
+// NewNomadCollector returns a new CCollector combining the containers from
+// the given collector into nomad allocations.  Allocations are identified by
+// the docker labels of their tasks.  The client is used to look up the
+// job, task group, resources and status of each allocation.  It may be nil
+// if the driver sets the extra labels in which case resources are those of
+// the containers and an allocation is destroyed once its task containers
+// have not been replaced for DefaultRestartGrace
+func NewNomadCollector(cc CCollector, client *nomad.Client, logger *zap.Logger) CCollector {
+	return newNomadCollector(cc, client, DefaultRestartGrace, logger)
+}
+
+func newNomadCollector(cc CCollector, client *nomad.Client, restartGrace time.Duration, logger *zap.Logger) *nCollector {
+	nc := &nCollector{
+		cc:           cc,
+		client:       client,
+		allocs:       make(map[string]*allocation),
+		tasks:        make(map[string]string),
+		lookups:      make(chan allocLookup, 8),
+		restartGrace: restartGrace,
+		out:          make(chan types.Container, 32),
+		stats:        make(chan types.ContainerStats, 32),
+		done:         make(chan struct{}),
+		log:          logger,
+	}
+	if nc.log == nil {
+		nc.log, _ = zap.NewDevelopment()
+	}
+	go nc.run()
+	return nc
+}
+
+// run exits once the underlying collector closes both its channels
+func (nc *nCollector) run() {
+	defer close(nc.done)
+	defer close(nc.stats)
+	defer close(nc.out)
+
+	check := time.NewTicker(nc.restartGrace / 2)
+	defer check.Stop()
+
+	updates, stats := nc.cc.Updates(), nc.cc.Stats()
+	for updates != nil || stats != nil {
+		select {
+		case c, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			nc.handleUpdate(c)
+
+		case s, ok := <-stats:
+			if !ok {
+				stats = nil
+				continue
+			}
+			nc.handleStats(s)
+
+		case l := <-nc.lookups:
+			nc.handleLookup(l)
+
+		case <-check.C:
+			nc.checkEnded()
+		}
+	}
+}
+
+func (nc *nCollector) handleUpdate(c types.Container) {
+	a, ok := nc.allocs[nc.tasks[c.ID]]
+	if !ok {
+		alloc := nomad.FromLabels(c.Labels)
+		if alloc == nil {
+			nc.out <- c
+			return
+		}
+		if a, ok = nc.allocs[alloc.ID]; !ok {
+			a = &allocation{
+				alloc:   alloc,
+				tasks:   make(map[string]types.Container),
+				samples: make(map[string]types.ContainerStats),
+			}
+			nc.allocs[alloc.ID] = a
+			nc.lookup(a)
+		}
+		nc.tasks[c.ID] = alloc.ID
+	}
+	a.tasks[c.ID] = c
+
+	// A restart destroys the task container before creating its
+	// replacement so the status decides whether the allocation ended
+	if running, _ := a.running(); !running {
+		nc.lookup(a)
+	}
+	nc.send(a)
+}
+
+// lookup gets the allocation from the agent without blocking the update
+// loop.  The result is handled by handleLookup
+func (nc *nCollector) lookup(a *allocation) {
+	if nc.client == nil || a.looking {
+		return
+	}
+	a.looking = true
+
+	id := a.alloc.ID
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		alloc, err := nc.client.Allocation(ctx, id)
+		select {
+		case nc.lookups <- allocLookup{id: id, alloc: alloc, err: err}:
+		case <-nc.done:
+		}
+	}()
+}
+
+// handleLookup completes the allocation from the agent and ends it if its
+// tasks are gone and it will not run again
+func (nc *nCollector) handleLookup(l allocLookup) {
+	a, ok := nc.allocs[l.id]
+	if !ok {
+		return
+	}
+	a.looking = false
+	a.known = l.err == nil
+	if l.err != nil {
+		nc.log.Info("failed to get allocation", zap.String("alloc", l.id), zap.Error(l.err))
+		return
+	}
+
+	if a.mhz == 0 && a.mem == 0 {
+		nc.log.Debug("tracking",
+			zap.String("alloc", l.alloc.Name),
+			zap.String("namespace", l.alloc.Namespace),
+		)
+	}
+	a.alloc = l.alloc
+	a.mhz, a.mem = l.alloc.Resources()
+	if running, last := a.running(); !running && l.alloc.Terminal() {
+		a.ended = last
+	}
+	nc.send(a)
+}
+
+// checkEnded ends allocations whose task containers have not been replaced
+// within the grace period.  While the agent can be reached its status
+// decides instead
+func (nc *nCollector) checkEnded() {
+	for _, a := range nc.allocs {
+		running, last := a.running()
+		if running || a.looking {
+			continue
+		}
+		if a.known || time.Since(time.Unix(0, last)) < nc.restartGrace {
+			nc.lookup(a)
+			continue
+		}
+		a.ended = last
+		nc.send(a)
+	}
+}
+
+// send sends the allocation if it changed since the last update.  Ended
+// allocations are no longer tracked
+func (nc *nCollector) send(a *allocation) {
+	ac := a.container()
+	if a.sent == nil || !reflect.DeepEqual(*a.sent, ac) {
+		a.sent = &ac
+		nc.out <- ac
+	}
+
+	if ac.Destroyed() {
+		for id := range a.tasks {
+			delete(nc.tasks, id)
+		}
+		delete(nc.allocs, ac.ID)
+		nc.log.Debug("allocation destroyed",
+			zap.String("alloc", ac.Name),
+			zap.Duration("alloctime", ac.AllocatedTime()),
+		)
+	}
+}
+
+func (nc *nCollector) handleStats(s types.ContainerStats) {
+	a, ok := nc.allocs[nc.tasks[s.ID]]
+	if !ok {
+		nc.stats <- s
+		return
+	}
+	a.samples[s.ID] = s
+	nc.stats <- a.stats(s.Timestamp)
+}
+
+func (nc *nCollector) Updates() <-chan types.Container {
+	return nc.out
+}
+
+func (nc *nCollector) Stats() <-chan types.ContainerStats {
+	return nc.stats
+}
+
+func (nc *nCollector) Stop() error {
+	err := nc.cc.Stop()
+	<-nc.done
+	return err
+}
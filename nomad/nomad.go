@@ -0,0 +1,185 @@
+// Package nomad implements a minimal client of the nomad agent api used to
+// meter allocations rather than the containers of their tasks.
+package nomad
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAddr is the http api of the local nomad agent
+const DefaultAddr = "http://127.0.0.1:4646"
+
+// Labels the nomad docker driver sets on task containers.  Only the alloc
+// id is set by default, the others require the driver extra_labels option
+const (
+	DockerLabelAllocID   = "com.hashicorp.nomad.alloc_id"
+	DockerLabelJobName   = "com.hashicorp.nomad.job_name"
+	DockerLabelTaskGroup = "com.hashicorp.nomad.task_group_name"
+	DockerLabelTaskName  = "com.hashicorp.nomad.task_name"
+	DockerLabelNamespace = "com.hashicorp.nomad.namespace"
+)
+
+// Client statuses of an allocation that will not run again
+const (
+	ClientStatusComplete = "complete"
+	ClientStatusFailed   = "failed"
+	ClientStatusLost     = "lost"
+)
+
+// Labels set on metered allocations
+const (
+	LabelNamespace = "nomad.namespace"
+	LabelJob       = "nomad.job"
+	LabelTaskGroup = "nomad.group"
+	LabelAllocID   = "nomad.alloc_id"
+	LabelAllocName = "nomad.alloc_name"
+)
+
+// Allocation is the subset of a nomad allocation used for metering
+type Allocation struct {
+	ID                 string
+	Name               string
+	Namespace          string
+	JobID              string
+	TaskGroup          string
+	ClientStatus       string
+	AllocatedResources *AllocatedResources `json:",omitempty"`
+}
+
+// AllocatedResources are the resources reserved for an allocation
+type AllocatedResources struct {
+	Tasks map[string]AllocatedTaskResources
+}
+
+// AllocatedTaskResources are the resources reserved for a task
+type AllocatedTaskResources struct {
+	Cpu    AllocatedCpuResources
+	Memory AllocatedMemoryResources
+}
+
+// AllocatedCpuResources is the cpu reserved for a task in MHz
+type AllocatedCpuResources struct {
+	CpuShares int64
+}
+
+// AllocatedMemoryResources is the memory reserved for a task
+type AllocatedMemoryResources struct {
+	MemoryMB int64
+}
+
+// FromLabels returns the allocation the docker labels belong to or nil if
+// they are not of a nomad task.  Only the fields present as labels are set
+func FromLabels(labels map[string]string) *Allocation {
+	id := labels[DockerLabelAllocID]
+	if id == "" {
+		return nil
+	}
+	return &Allocation{
+		ID:        id,
+		Namespace: labels[DockerLabelNamespace],
+		JobID:     labels[DockerLabelJobName],
+		TaskGroup: labels[DockerLabelTaskGroup],
+	}
+}
+
+// Resources returns the cpu in MHz and the memory in bytes reserved for all
+// tasks of the allocation.  Both are zero if unknown
+func (alloc *Allocation) Resources() (mhz, mem int64) {
+	if alloc.AllocatedResources == nil {
+		return 0, 0
+	}
+	for _, task := range alloc.AllocatedResources.Tasks {
+		mhz += task.Cpu.CpuShares
+		mem += task.Memory.MemoryMB << 20
+	}
+	return mhz, mem
+}
+
+// Terminal returns true if the allocation has finished on the client and
+// its tasks will not be restarted
+func (alloc *Allocation) Terminal() bool {
+	switch alloc.ClientStatus {
+	case ClientStatusComplete, ClientStatusFailed, ClientStatusLost:
+		return true
+	}
+	return false
+}
+
+// Labels returns the labels identifying the allocation.  Unknown fields are
+// omitted
+func (alloc *Allocation) Labels() map[string]string {
+	labels := map[string]string{LabelAllocID: alloc.ID}
+	for k, v := range map[string]string{
+		LabelNamespace: alloc.Namespace,
+		LabelJob:       alloc.JobID,
+		LabelTaskGroup: alloc.TaskGroup,
+		LabelAllocName: alloc.Name,
+	} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// Config is the configuration of a nomad client
+type Config struct {
+	// Address of the agent http api.  Defaults to DefaultAddr
+	Addr string
+	// Optional acl token
+	Token   string
+	Timeout time.Duration
+}
+
+// Client is a nomad agent api client
+type Client struct {
+	addr  string
+	token string
+	hc    *http.Client
+}
+
+// NewClient returns a new nomad client for the config
+func NewClient(conf Config) *Client {
+	client := &Client{
+		addr:  strings.TrimSuffix(conf.Addr, "/"),
+		token: conf.Token,
+		hc:    &http.Client{Timeout: conf.Timeout},
+	}
+	if client.addr == "" {
+		client.addr = DefaultAddr
+	}
+	if client.hc.Timeout == 0 {
+		client.hc.Timeout = 10 * time.Second
+	}
+	return client
+}
+
+// Allocation returns the allocation by the given id
+func (client *Client) Allocation(ctx context.Context, id string) (*Allocation, error) {
+	req, err := http.NewRequest("GET", client.addr+"/v1/allocation/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if client.token != "" {
+		req.Header.Set("X-Nomad-Token", client.token)
+	}
+
+	resp, err := client.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("nomad allocation %s: %s", id, resp.Status)
+	}
+	var alloc Allocation
+	err = json.NewDecoder(resp.Body).Decode(&alloc)
+	return &alloc, err
+}
@@ -0,0 +1,75 @@
+package nomad
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAlloc = `{
+	"ID":"5456bd7a-9fc0-c0dd-6131-cbee77f57577",
+	"Name":"api.web[0]",
+	"Namespace":"shop",
+	"JobID":"api",
+	"TaskGroup":"web",
+	"ClientStatus":"running",
+	"AllocatedResources":{"Tasks":{
+		"server":{"Cpu":{"CpuShares":500},"Memory":{"MemoryMB":256}},
+		"proxy":{"Cpu":{"CpuShares":100},"Memory":{"MemoryMB":64}}
+	}}
+}`
+
+func Test_FromLabels(t *testing.T) {
+	assert.Nil(t, FromLabels(map[string]string{"app": "web"}))
+
+	alloc := FromLabels(map[string]string{
+		DockerLabelAllocID: "abc",
+		DockerLabelJobName: "api",
+	})
+	assert.Equal(t, "abc", alloc.ID)
+	assert.Equal(t, "api", alloc.JobID)
+	assert.Equal(t, map[string]string{LabelAllocID: "abc", LabelJob: "api"}, alloc.Labels())
+
+	mhz, mem := alloc.Resources()
+	assert.Equal(t, int64(0), mhz)
+	assert.Equal(t, int64(0), mem)
+}
+
+func Test_Client_Allocation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Nomad-Token") != "secret" {
+			w.WriteHeader(403)
+			return
+		}
+		if r.URL.Path != "/v1/allocation/5456bd7a-9fc0-c0dd-6131-cbee77f57577" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write([]byte(testAlloc))
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{Addr: srv.URL, Token: "secret"})
+	alloc, err := client.Allocation(context.Background(), "5456bd7a-9fc0-c0dd-6131-cbee77f57577")
+	assert.Nil(t, err)
+	assert.Equal(t, "api.web[0]", alloc.Name)
+
+	mhz, mem := alloc.Resources()
+	assert.Equal(t, int64(600), mhz)
+	assert.Equal(t, int64(320<<20), mem)
+
+	labels := alloc.Labels()
+	assert.Equal(t, "shop", labels[LabelNamespace])
+	assert.Equal(t, "api", labels[LabelJob])
+	assert.Equal(t, "web", labels[LabelTaskGroup])
+	assert.Equal(t, "api.web[0]", labels[LabelAllocName])
+	assert.False(t, alloc.Terminal())
+	alloc.ClientStatus = ClientStatusFailed
+	assert.True(t, alloc.Terminal())
+
+	_, err = client.Allocation(context.Background(), "missing")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,198 @@
+package metermaid
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/euforia/metermaid/nomad"
+	"github.com/euforia/metermaid/types"
+)
+
+// allocState is the part of a metered container checked by the tests
+type allocState struct {
+	ID                           string
+	Create, Start, Stop, Destroy int64
+	CPUShares                    int64
+}
+
+func stateOf(c types.Container) allocState {
+	return allocState{c.ID, c.Create, c.Start, c.Stop, c.Destroy, c.CPUShares}
+}
+
+func taskContainer(id, task string, create, stop, destroy int64) types.Container {
+	return types.Container{
+		ID:      id,
+		Create:  create,
+		Start:   create + 1,
+		Stop:    stop,
+		Destroy: destroy,
+		Labels: map[string]string{
+			nomad.DockerLabelAllocID:  "alloc-1",
+			nomad.DockerLabelTaskName: task,
+		},
+		CPU: &types.CPUReservation{Shares: 100},
+	}
+}
+
+// testNomadCollector returns a collector whose loop is driven by the test
+func testNomadCollector(client *nomad.Client) *nCollector {
+	return &nCollector{
+		client:  client,
+		allocs:  make(map[string]*allocation),
+		tasks:   make(map[string]string),
+		lookups: make(chan allocLookup, 8),
+		out:     make(chan types.Container, 32),
+		stats:   make(chan types.ContainerStats, 32),
+		done:    make(chan struct{}),
+		log:     zap.NewNop(),
+	}
+}
+
+// settle handles agent lookups until none are in flight
+func (nc *nCollector) settle() {
+	for {
+		looking := false
+		for _, a := range nc.allocs {
+			looking = looking || a.looking
+		}
+		if !looking {
+			return
+		}
+		nc.handleLookup(<-nc.lookups)
+	}
+}
+
+func Test_nCollector(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// Client status served by the agent.  No agent if empty
+		status string
+		in     []types.Container
+		// Whether ended allocations are checked after the updates
+		check bool
+		want  []allocState
+	}{
+		{
+			name: "passes through other containers",
+			in:   []types.Container{{ID: "other", Create: 5, CPUShares: 10}},
+			want: []allocState{{ID: "other", Create: 5, CPUShares: 10}},
+		},
+		{
+			name: "aggregates tasks",
+			in: []types.Container{
+				taskContainer("c1", "web", 10, 0, 0),
+				taskContainer("c2", "proxy", 20, 0, 0),
+			},
+			want: []allocState{
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 100},
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 200},
+			},
+		},
+		{
+			name: "restart keeps allocation",
+			in: []types.Container{
+				taskContainer("c1", "web", 10, 0, 0),
+				taskContainer("c1", "web", 10, 30, 31),
+				taskContainer("c2", "web", 32, 0, 0),
+			},
+			check: true,
+			want: []allocState{
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 100},
+				{ID: "alloc-1", Create: 10, Start: 11, Stop: 30, CPUShares: 100},
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 100},
+			},
+		},
+		{
+			name: "ends without replacement",
+			in: []types.Container{
+				taskContainer("c1", "web", 10, 0, 0),
+				taskContainer("c1", "web", 10, 30, 31),
+			},
+			check: true,
+			want: []allocState{
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 100},
+				{ID: "alloc-1", Create: 10, Start: 11, Stop: 30, CPUShares: 100},
+				{ID: "alloc-1", Create: 10, Start: 11, Stop: 30, Destroy: 31, CPUShares: 100},
+			},
+		},
+		{
+			name:   "running on agent keeps allocation",
+			status: "running",
+			in: []types.Container{
+				taskContainer("c1", "web", 10, 0, 0),
+				taskContainer("c1", "web", 10, 30, 31),
+			},
+			check: true,
+			want: []allocState{
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 100},
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 500},
+				{ID: "alloc-1", Create: 10, Start: 11, Stop: 30, CPUShares: 500},
+			},
+		},
+		{
+			name:   "terminal on agent ends allocation",
+			status: nomad.ClientStatusComplete,
+			in: []types.Container{
+				taskContainer("c1", "web", 10, 0, 0),
+				taskContainer("c1", "web", 10, 30, 31),
+			},
+			want: []allocState{
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 100},
+				{ID: "alloc-1", Create: 10, Start: 11, CPUShares: 500},
+				{ID: "alloc-1", Create: 10, Start: 11, Stop: 30, CPUShares: 500},
+				{ID: "alloc-1", Create: 10, Start: 11, Stop: 30, Destroy: 31, CPUShares: 500},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var client *nomad.Client
+			if tc.status != "" {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, `{"ID":"alloc-1","ClientStatus":%q,
+						"AllocatedResources":{"Tasks":{"web":{"Cpu":{"CpuShares":500}}}}}`, tc.status)
+				}))
+				defer srv.Close()
+				client = nomad.NewClient(nomad.Config{Addr: srv.URL})
+			}
+
+			nc := testNomadCollector(client)
+			for _, c := range tc.in {
+				nc.handleUpdate(c)
+				nc.settle()
+			}
+			if tc.check {
+				nc.checkEnded()
+				nc.settle()
+			}
+			close(nc.out)
+
+			var got []allocState
+			for c := range nc.out {
+				got = append(got, stateOf(c))
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_nCollector_stats(t *testing.T) {
+	nc := testNomadCollector(nil)
+	nc.handleUpdate(taskContainer("c1", "web", 10, 0, 0))
+	nc.handleUpdate(taskContainer("c2", "proxy", 10, 0, 0))
+
+	nc.handleStats(types.ContainerStats{ID: "c1", NetTxBytes: 100})
+	nc.handleStats(types.ContainerStats{ID: "c2", NetTxBytes: 50})
+	nc.handleStats(types.ContainerStats{ID: "other", NetTxBytes: 7})
+	close(nc.stats)
+
+	var got []uint64
+	for s := range nc.stats {
+		got = append(got, s.NetTxBytes)
+	}
+	assert.Equal(t, []uint64{100, 150, 7}, got)
+}
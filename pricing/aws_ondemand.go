@@ -165,7 +165,7 @@ func parseNonSpotJSON(v map[string]interface{}) (tsdb.DataPoints, error) {
 	for _, ivv := range pdim {
 		a := ivv.(map[string]interface{})
 		ppu := a["pricePerUnit"].(map[string]interface{})
-		value, err := strconv.ParseFloat(ppu["USD"].(string), 64)
+		value, err := strconv.ParseFloat(ppu[BaseCurrency].(string), 64)
 		// return
 		if err == nil {
 			dps = dps.Insert(tsdb.DataPoint{Timestamp: ts, Value: value})
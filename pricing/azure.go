@@ -0,0 +1,150 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+)
+
+const (
+	// AzureLifecycleSpot is the Lifecycle filter value selecting spot meters
+	AzureLifecycleSpot = "spot"
+	// AzureOSWindows is the OS filter value selecting windows meters
+	AzureOSWindows = "Windows"
+)
+
+// AzureRetailPrice is a single item from the Azure retail prices api
+// i.e. https://prices.azure.com/api/retail/prices
+type AzureRetailPrice struct {
+	CurrencyCode       string
+	RetailPrice        float64
+	UnitPrice          float64
+	ArmRegionName      string
+	Location           string
+	EffectiveStartDate string
+	MeterName          string
+	ProductName        string
+	SkuName            string
+	ServiceName        string
+	ArmSkuName         string
+	Type               string
+	UnitOfMeasure      string
+}
+
+// Spot returns true if the price is for a spot or low priority meter
+func (item *AzureRetailPrice) Spot() bool {
+	return strings.HasSuffix(item.SkuName, " Spot") ||
+		strings.HasSuffix(item.SkuName, " Low Priority")
+}
+
+// Windows returns true if the price is for a windows meter
+func (item *AzureRetailPrice) Windows() bool {
+	return strings.HasSuffix(item.ProductName, " Windows")
+}
+
+// AzurePricer provides azure vm pricing from a retail prices export on
+// disk. The export can be a single api response or a set of concatenated
+// page responses. As all providers price in the BaseCurrency the export
+// must be fetched in it, which is the api default.
+type AzurePricer struct {
+	items []AzureRetailPrice
+}
+
+// NewAzurePricer loads the retail prices export at the given path and
+// returns a new instance of AzurePricer
+func NewAzurePricer(path string) (*AzurePricer, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	items, err := parseAzureRetailPrices(fh)
+	if err == nil {
+		return &AzurePricer{items: items}, nil
+	}
+	return nil, err
+}
+
+// Name returns the name of the pricer
+func (pp *AzurePricer) Name() string {
+	return "azure-retail"
+}
+
+// History returns the price history given the filter. Region and
+// InstanceType are required filter keys and map to the arm region and sku
+// names. Lifecycle=spot selects spot meters and OS=Windows selects windows
+// meters, defaulting to pay-as-you-go linux.
+func (pp *AzurePricer) History(start, end time.Time, filter map[string]string) (tsdb.DataPoints, error) {
+	region, ok := filter["Region"]
+	if !ok {
+		return nil, errors.New("region required")
+	}
+	sku, ok := filter["InstanceType"]
+	if !ok {
+		return nil, errors.New("instance type required")
+	}
+
+	var (
+		spot    = strings.EqualFold(filter["Lifecycle"], AzureLifecycleSpot)
+		windows = strings.EqualFold(filter["OS"], AzureOSWindows)
+		dps     = make(tsdb.DataPoints, 0)
+	)
+
+	for _, item := range pp.items {
+		if item.Type != "Consumption" || item.UnitOfMeasure != "1 Hour" ||
+			!strings.EqualFold(item.ArmRegionName, region) ||
+			!strings.EqualFold(item.ArmSkuName, sku) ||
+			item.Spot() != spot || item.Windows() != windows {
+			continue
+		}
+
+		eff, err := time.Parse(time.RFC3339, item.EffectiveStartDate)
+		if err != nil {
+			return nil, err
+		}
+		dps = dps.Insert(tsdb.DataPoint{
+			Timestamp: uint64(eff.UnixNano()),
+			Value:     item.RetailPrice,
+		})
+	}
+
+	if len(dps) == 0 {
+		return nil, errors.New("no matching azure prices")
+	}
+
+	sort.Sort(dps)
+	return dps.Dedup(), nil
+}
+
+func parseAzureRetailPrices(r io.Reader) ([]AzureRetailPrice, error) {
+	var (
+		dec   = json.NewDecoder(r)
+		items []AzureRetailPrice
+	)
+	for {
+		var page struct {
+			Items []AzureRetailPrice
+		}
+		err := dec.Decode(&page)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			if item.CurrencyCode != "" && item.CurrencyCode != BaseCurrency {
+				return nil, fmt.Errorf("azure price in %s not %s", item.CurrencyCode, BaseCurrency)
+			}
+		}
+		items = append(items, page.Items...)
+	}
+	return items, nil
+}
@@ -0,0 +1,57 @@
+package pricing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAzurePrices = `{"Items":[
+{"currencyCode":"USD","retailPrice":0.096,"armRegionName":"eastus","effectiveStartDate":"2020-01-01T00:00:00Z","meterName":"D2s v3","productName":"Virtual Machines DSv3 Series","skuName":"D2s v3","armSkuName":"Standard_D2s_v3","type":"Consumption","unitOfMeasure":"1 Hour"},
+{"currencyCode":"USD","retailPrice":0.0192,"armRegionName":"eastus","effectiveStartDate":"2020-01-01T00:00:00Z","meterName":"D2s v3 Spot","productName":"Virtual Machines DSv3 Series","skuName":"D2s v3 Spot","armSkuName":"Standard_D2s_v3","type":"Consumption","unitOfMeasure":"1 Hour"},
+{"currencyCode":"USD","retailPrice":0.188,"armRegionName":"eastus","effectiveStartDate":"2020-01-01T00:00:00Z","meterName":"D2s v3","productName":"Virtual Machines DSv3 Series Windows","skuName":"D2s v3","armSkuName":"Standard_D2s_v3","type":"Consumption","unitOfMeasure":"1 Hour"},
+{"currencyCode":"USD","retailPrice":500,"armRegionName":"eastus","effectiveStartDate":"2020-01-01T00:00:00Z","meterName":"D2s v3","productName":"Virtual Machines DSv3 Series","skuName":"D2s v3","armSkuName":"Standard_D2s_v3","type":"Reservation","unitOfMeasure":"1 Hour"}
+]}
+{"Items":[
+{"currencyCode":"USD","retailPrice":0.101,"armRegionName":"eastus","effectiveStartDate":"2021-01-01T00:00:00Z","meterName":"D2s v3","productName":"Virtual Machines DSv3 Series","skuName":"D2s v3","armSkuName":"Standard_D2s_v3","type":"Consumption","unitOfMeasure":"1 Hour"}
+]}`
+
+func Test_AzurePricer(t *testing.T) {
+	items, err := parseAzureRetailPrices(strings.NewReader(testAzurePrices))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(items))
+
+	p := &AzurePricer{items: items}
+	now := time.Now()
+
+	dps, err := p.History(now, now, map[string]string{
+		"Region": "eastus", "InstanceType": "Standard_D2s_v3",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(dps))
+	assert.Equal(t, 0.101, dps.Last().Value)
+
+	dps, err = p.History(now, now, map[string]string{
+		"Region": "eastus", "InstanceType": "Standard_D2s_v3", "Lifecycle": "spot",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(dps))
+	assert.Equal(t, 0.0192, dps[0].Value)
+
+	dps, err = p.History(now, now, map[string]string{
+		"Region": "eastus", "InstanceType": "Standard_D2s_v3", "OS": "Windows",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 0.188, dps[0].Value)
+
+	_, err = p.History(now, now, map[string]string{
+		"Region": "westus", "InstanceType": "Standard_D2s_v3",
+	})
+	assert.NotNil(t, err)
+
+	// Prices must be in the base currency
+	_, err = parseAzureRetailPrices(strings.NewReader(strings.Replace(testAzurePrices, `"USD"`, `"EUR"`, 1)))
+	assert.NotNil(t, err)
+}
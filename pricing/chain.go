@@ -0,0 +1,160 @@
+package pricing
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+)
+
+// Confidence is the level of trust in a price
+type Confidence int
+
+const (
+	// ConfidenceUnknown is used when the source of a price is not known
+	ConfidenceUnknown Confidence = iota
+	// ConfidenceLow is for estimates e.g. a static table
+	ConfidenceLow
+	// ConfidenceMedium is for previously fetched or cached prices
+	ConfidenceMedium
+	// ConfidenceHigh is for prices from the authoritative api
+	ConfidenceHigh
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	}
+	return "unknown"
+}
+
+// MarshalText satisfies the encoding.TextMarshaler interface
+func (c Confidence) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText satisfies the encoding.TextUnmarshaler interface
+func (c *Confidence) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*c = ConfidenceLow
+	case "medium":
+		*c = ConfidenceMedium
+	case "high":
+		*c = ConfidenceHigh
+	default:
+		*c = ConfidenceUnknown
+	}
+	return nil
+}
+
+// Source holds the provenance of a price
+type Source struct {
+	Provider   string
+	Confidence Confidence
+}
+
+// Sources is a set of price provenances
+type Sources []Source
+
+// Confidence returns the lowest confidence of all sources
+func (srcs Sources) Confidence() Confidence {
+	if len(srcs) == 0 {
+		return ConfidenceUnknown
+	}
+	min := srcs[0].Confidence
+	for _, src := range srcs[1:] {
+		if src.Confidence < min {
+			min = src.Confidence
+		}
+	}
+	return min
+}
+
+// Providers returns the unique provider names in order of appearance
+func (srcs Sources) Providers() []string {
+	out := make([]string, 0, 1)
+	for _, src := range srcs {
+		if len(out) > 0 && out[len(out)-1] == src.Provider {
+			continue
+		}
+		dup := false
+		for _, name := range out {
+			if name == src.Provider {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, src.Provider)
+		}
+	}
+	return out
+}
+
+// SourcedProvider is a Provider that also reports where the returned prices
+// came from
+type SourcedProvider interface {
+	Provider
+	SourcedHistory(start, end time.Time, filter map[string]string) (tsdb.DataPoints, Source, error)
+}
+
+// ChainLink is a single provider in a chain along with the confidence of the
+// prices it returns
+type ChainLink struct {
+	Provider   Provider
+	Confidence Confidence
+}
+
+// ChainProvider tries each provider in order returning the first successful
+// non-empty result
+type ChainProvider struct {
+	links []ChainLink
+}
+
+// NewChainProvider returns a new ChainProvider trying the links in the
+// given order
+func NewChainProvider(links ...ChainLink) *ChainProvider {
+	return &ChainProvider{links: links}
+}
+
+// Name returns the name of the chain made up of all provider names
+func (pp *ChainProvider) Name() string {
+	names := make([]string, len(pp.links))
+	for i, link := range pp.links {
+		names[i] = link.Provider.Name()
+	}
+	return "chain(" + strings.Join(names, ",") + ")"
+}
+
+// History satisfies the Provider interface
+func (pp *ChainProvider) History(start, end time.Time, filter map[string]string) (tsdb.DataPoints, error) {
+	dps, _, err := pp.SourcedHistory(start, end, filter)
+	return dps, err
+}
+
+// SourcedHistory satisfies the SourcedProvider interface. The returned error
+// contains the errors of all providers if none succeeded
+func (pp *ChainProvider) SourcedHistory(start, end time.Time, filter map[string]string) (tsdb.DataPoints, Source, error) {
+	if len(pp.links) == 0 {
+		return nil, Source{}, errors.New("no price providers")
+	}
+	errs := make([]string, 0, len(pp.links))
+	for _, link := range pp.links {
+		dps, err := link.Provider.History(start, end, filter)
+		if err == nil && len(dps) > 0 {
+			return dps, Source{Provider: link.Provider.Name(), Confidence: link.Confidence}, nil
+		}
+		if err == nil {
+			err = errors.New("no prices")
+		}
+		errs = append(errs, link.Provider.Name()+": "+err.Error())
+	}
+	return nil, Source{}, errors.New("all providers failed: " + strings.Join(errs, "; "))
+}
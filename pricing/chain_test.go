@@ -0,0 +1,51 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFailPricer struct{}
+
+func (pp *testFailPricer) Name() string { return "fail" }
+
+func (pp *testFailPricer) History(start, end time.Time, filter map[string]string) (tsdb.DataPoints, error) {
+	return nil, errors.New("unavailable")
+}
+
+func Test_ChainProvider(t *testing.T) {
+	chain := NewChainProvider(
+		ChainLink{Provider: &testFailPricer{}, Confidence: ConfidenceHigh},
+		ChainLink{Provider: NewStaticPricer(map[string]float64{"m5.xlarge": 0.192}), Confidence: ConfidenceLow},
+	)
+	assert.Equal(t, "chain(fail,static)", chain.Name())
+
+	now := time.Now()
+	dps, src, err := chain.SourcedHistory(now, now, map[string]string{"InstanceType": "m5.xlarge"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(dps))
+	assert.Equal(t, "static", src.Provider)
+	assert.Equal(t, ConfidenceLow, src.Confidence)
+
+	_, _, err = chain.SourcedHistory(now, now, map[string]string{"InstanceType": "c5.xlarge"})
+	assert.NotNil(t, err)
+
+	// Nothing is priced without providers
+	_, err = NewChainProvider().History(now, now, map[string]string{"InstanceType": "m5.xlarge"})
+	assert.NotNil(t, err)
+}
+
+func Test_Sources(t *testing.T) {
+	srcs := Sources{
+		{Provider: "aws-spot", Confidence: ConfidenceHigh},
+		{Provider: "static", Confidence: ConfidenceLow},
+		{Provider: "aws-spot", Confidence: ConfidenceHigh},
+	}
+	assert.Equal(t, ConfidenceLow, srcs.Confidence())
+	assert.Equal(t, []string{"aws-spot", "static"}, srcs.Providers())
+	assert.Equal(t, ConfidenceUnknown, Sources{}.Confidence())
+}
@@ -0,0 +1,262 @@
+package pricing
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/tsdb"
+)
+
+// hoursPerYear is used to amortize upfront fees over the term
+const hoursPerYear = 8760
+
+// ReservedInstance describes a purchased reserved instance. A zonal
+// reservation sets the AvailabilityZone, a regional one only the Region.
+type ReservedInstance struct {
+	InstanceType     string
+	Region           string
+	AvailabilityZone string
+	// Number of instances reserved. Zero is one
+	Count     int
+	Start     time.Time
+	TermYears int
+	// One time fee paid at purchase
+	Upfront float64
+	// Hourly fee paid for the duration of the term
+	RecurringHourly float64
+}
+
+// End returns the time the reservation expires
+func (ri *ReservedInstance) End() time.Time {
+	return ri.Start.AddDate(ri.TermYears, 0, 0)
+}
+
+// Hourly returns the effective hourly rate with the upfront fee amortized
+// over the term
+func (ri *ReservedInstance) Hourly() float64 {
+	if ri.TermYears <= 0 {
+		return ri.RecurringHourly
+	}
+	return ri.Upfront/float64(ri.TermYears*hoursPerYear) + ri.RecurringHourly
+}
+
+// count returns the number of instances reserved
+func (ri *ReservedInstance) count() int {
+	if ri.Count < 1 {
+		return 1
+	}
+	return ri.Count
+}
+
+// Matches returns true if the reservation applies to a node with the given
+// meta at time ts.  Reservations do not apply to spot instances
+func (ri *ReservedInstance) Matches(ts time.Time, meta map[string]string) bool {
+	if ts.Before(ri.Start) || !ts.Before(ri.End()) || spot(meta) {
+		return false
+	}
+	if ri.InstanceType != meta["InstanceType"] {
+		return false
+	}
+	if ri.AvailabilityZone != "" {
+		return ri.AvailabilityZone == meta["AvailabilityZone"]
+	}
+	return ri.Region == "" || ri.Region == meta["Region"]
+}
+
+// SavingsPlan describes a purchased savings plan. Compute savings plans
+// leave InstanceFamily and Region empty.
+type SavingsPlan struct {
+	// Instance family e.g. m5 for ec2 instance savings plans
+	InstanceFamily string
+	Region         string
+	Start          time.Time
+	TermYears      int
+	// Committed spend per hour
+	HourlyCommitment float64
+	// Discount off the on-demand rate e.g. 0.3 for 30%
+	Discount float64
+	// Number of nodes the commitment is spread across. Zero means the whole
+	// commitment is applied to each node.
+	Nodes int
+}
+
+// End returns the time the savings plan expires
+func (sp *SavingsPlan) End() time.Time {
+	return sp.Start.AddDate(sp.TermYears, 0, 0)
+}
+
+// Matches returns true if the savings plan applies to a node with the given
+// meta at time ts.  Savings plans do not apply to spot instances
+func (sp *SavingsPlan) Matches(ts time.Time, meta map[string]string) bool {
+	if ts.Before(sp.Start) || !ts.Before(sp.End()) || spot(meta) {
+		return false
+	}
+	if sp.Region != "" && sp.Region != meta["Region"] {
+		return false
+	}
+	return sp.InstanceFamily == "" ||
+		strings.HasPrefix(meta["InstanceType"], sp.InstanceFamily+".")
+}
+
+// spot returns true if the meta is of a spot instance
+func spot(meta map[string]string) bool {
+	return meta[node.LifecycleTag] == node.LifecycleSpot
+}
+
+// Hourly returns the effective hourly rate for a node with the given
+// on-demand rate. The node pays its share of the commitment whether used or
+// not, and any usage beyond what the share covers at the discounted rate is
+// paid at the on-demand rate.
+func (sp *SavingsPlan) Hourly(onDemand float64) float64 {
+	share := sp.HourlyCommitment
+	if sp.Nodes > 0 {
+		share /= float64(sp.Nodes)
+	}
+
+	// On-demand equivalent usage covered by the share
+	covered := share
+	if sp.Discount < 1 {
+		covered = share / (1 - sp.Discount)
+	}
+	if onDemand <= covered {
+		return share
+	}
+	return share + onDemand - covered
+}
+
+// Commitments holds purchased reservations and savings plans used to
+// compute the effective amortized rate of a node
+type Commitments struct {
+	ReservedInstances []ReservedInstance
+	SavingsPlans      []SavingsPlan
+
+	// Optional metadata of the nodes in the fleet.  Reservations are spread
+	// across the nodes they match so each pays the reserved rate for the
+	// share covered.  Without it each reservation covers the node in full
+	Fleet func() []map[string]string `json:"-"`
+}
+
+// LoadCommitments loads commitments from the json file at the given path
+func LoadCommitments(path string) (*Commitments, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var c Commitments
+	if err = json.NewDecoder(fh).Decode(&c); err == nil {
+		return &c, nil
+	}
+	return nil, err
+}
+
+// Rate returns the effective hourly rate at time ts for a node with the
+// given meta and list rate.  Reservations take precedence over savings plans
+// as that is the order in which aws applies them.  Each reservation covers
+// the share of the node given by its count over the matching nodes in the
+// fleet, cheapest first.  The rest is paid at the best savings plan or list
+// rate
+func (c *Commitments) Rate(ts time.Time, list float64, meta map[string]string, fleet []map[string]string) float64 {
+	uncovered := list
+	for i := range c.SavingsPlans {
+		sp := &c.SavingsPlans[i]
+		if sp.Matches(ts, meta) {
+			uncovered = math.Min(uncovered, sp.Hourly(list))
+		}
+	}
+
+	reserved := make([]*ReservedInstance, 0, len(c.ReservedInstances))
+	for i := range c.ReservedInstances {
+		if ri := &c.ReservedInstances[i]; ri.Matches(ts, meta) {
+			reserved = append(reserved, ri)
+		}
+	}
+	sort.Slice(reserved, func(i, j int) bool { return reserved[i].Hourly() < reserved[j].Hourly() })
+
+	var (
+		rate      float64
+		remaining = 1.0
+	)
+	for _, ri := range reserved {
+		// The node itself matches even if not yet in the fleet
+		nodes := 1
+		if n := countMatching(ri, ts, fleet); n > nodes {
+			nodes = n
+		}
+		covered := math.Min(remaining, float64(ri.count())/float64(nodes))
+		rate += covered * ri.Hourly()
+		if remaining -= covered; remaining <= 0 {
+			return rate
+		}
+	}
+	return rate + remaining*uncovered
+}
+
+// countMatching returns the number of fleet nodes the reservation applies
+// to at time ts
+func countMatching(ri *ReservedInstance, ts time.Time, fleet []map[string]string) (n int) {
+	for _, meta := range fleet {
+		if ri.Matches(ts, meta) {
+			n++
+		}
+	}
+	return
+}
+
+// Apply returns the effective rates for the given list prices. Data points
+// are added where a commitment starts or ends between the first price and
+// end so the change in rate is accounted for.
+func (c *Commitments) Apply(prices tsdb.DataPoints, meta map[string]string, end uint64) tsdb.DataPoints {
+	if len(prices) == 0 {
+		return prices
+	}
+
+	var (
+		first  = prices[0].Timestamp
+		stamps = make([]uint64, 0, len(prices))
+	)
+	for _, p := range prices {
+		stamps = append(stamps, p.Timestamp)
+	}
+
+	addBoundary := func(t time.Time) {
+		if ts := uint64(t.UnixNano()); ts > first && ts < end {
+			stamps = append(stamps, ts)
+		}
+	}
+	for _, ri := range c.ReservedInstances {
+		addBoundary(ri.Start)
+		addBoundary(ri.End())
+	}
+	for _, sp := range c.SavingsPlans {
+		addBoundary(sp.Start)
+		addBoundary(sp.End())
+	}
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i] < stamps[j] })
+
+	var (
+		out   = make(tsdb.DataPoints, 0, len(stamps))
+		pi    int
+		fleet []map[string]string
+	)
+	if c.Fleet != nil {
+		fleet = c.Fleet()
+	}
+	for _, ts := range stamps {
+		// Move to the last list price at or before ts
+		for pi+1 < len(prices) && prices[pi+1].Timestamp <= ts {
+			pi++
+		}
+		out = out.Insert(tsdb.DataPoint{
+			Timestamp: ts,
+			Value:     c.Rate(time.Unix(0, int64(ts)), prices[pi].Value, meta, fleet),
+		})
+	}
+	return out.Dedup()
+}
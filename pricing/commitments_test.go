@@ -0,0 +1,106 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+	"github.com/stretchr/testify/assert"
+)
+
+var testCommitMeta = map[string]string{
+	"Region":           "us-west-2",
+	"AvailabilityZone": "us-west-2b",
+	"InstanceType":     "m5.xlarge",
+}
+
+func Test_ReservedInstance(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	ri := ReservedInstance{
+		InstanceType:     "m5.xlarge",
+		AvailabilityZone: "us-west-2b",
+		Start:            start,
+		TermYears:        1,
+		Upfront:          876,
+		RecurringHourly:  0.05,
+	}
+	assert.InDelta(t, 0.15, ri.Hourly(), 1e-9)
+	assert.True(t, ri.Matches(start, testCommitMeta))
+	assert.False(t, ri.Matches(start.Add(-time.Hour), testCommitMeta))
+	assert.False(t, ri.Matches(ri.End(), testCommitMeta))
+
+	ri.AvailabilityZone = "us-west-2a"
+	assert.False(t, ri.Matches(start, testCommitMeta))
+	ri.AvailabilityZone = ""
+	ri.Region = "us-west-2"
+	assert.True(t, ri.Matches(start, testCommitMeta))
+}
+
+func Test_SavingsPlan(t *testing.T) {
+	sp := SavingsPlan{HourlyCommitment: 0.7, Discount: 0.3}
+	// Fully covered usage still pays the whole commitment
+	assert.InDelta(t, 0.7, sp.Hourly(0.5), 1e-9)
+	// Usage beyond the covered 1.0 is paid on-demand
+	assert.InDelta(t, 0.9, sp.Hourly(1.2), 1e-9)
+
+	sp.Nodes = 2
+	assert.InDelta(t, 0.35, sp.Hourly(0.5), 1e-9)
+
+	sp.InstanceFamily = "c5"
+	assert.False(t, sp.Matches(time.Now(), testCommitMeta))
+}
+
+func Test_Commitments_Apply(t *testing.T) {
+	base, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	c := &Commitments{
+		ReservedInstances: []ReservedInstance{{
+			InstanceType:    "m5.xlarge",
+			Start:           base.Add(2 * time.Hour),
+			TermYears:       1,
+			RecurringHourly: 0.1,
+		}},
+	}
+
+	prices := tsdb.DataPoints{
+		{Timestamp: uint64(base.UnixNano()), Value: 0.2},
+	}
+	end := uint64(base.Add(4 * time.Hour).UnixNano())
+	out := c.Apply(prices, testCommitMeta, end)
+
+	assert.Equal(t, 2, len(out))
+	assert.Equal(t, 0.2, out[0].Value)
+	assert.Equal(t, 0.1, out[1].Value)
+	assert.EqualValues(t, base.Add(2*time.Hour).UnixNano(), out[1].Timestamp)
+}
+
+func Test_Commitments_Rate(t *testing.T) {
+	base, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	c := &Commitments{
+		ReservedInstances: []ReservedInstance{{
+			InstanceType:    "m5.xlarge",
+			Start:           base,
+			TermYears:       1,
+			RecurringHourly: 0.1,
+		}},
+		SavingsPlans: []SavingsPlan{{Start: base, TermYears: 1, HourlyCommitment: 0.05, Discount: 0.5}},
+	}
+	ts := base.Add(time.Hour)
+	other := map[string]string{"InstanceType": "c5.large"}
+
+	// A single reservation covers a single node in full
+	assert.InDelta(t, 0.1, c.Rate(ts, 0.2, testCommitMeta, nil), 1e-9)
+
+	// and a quarter of four matching nodes, the rest under the savings plan
+	fleet := []map[string]string{testCommitMeta, testCommitMeta, testCommitMeta, testCommitMeta, other}
+	assert.InDelta(t, 0.25*0.1+0.75*0.15, c.Rate(ts, 0.2, testCommitMeta, fleet), 1e-9)
+
+	c.ReservedInstances[0].Count = 2
+	c.ReservedInstances = append(c.ReservedInstances, ReservedInstance{
+		InstanceType: "m5.xlarge", Start: base, TermYears: 1, RecurringHourly: 0.12, Count: 4,
+	})
+	assert.InDelta(t, 0.5*0.1+0.5*0.12, c.Rate(ts, 0.2, testCommitMeta, fleet), 1e-9)
+
+	// Commitments do not apply to spot instances
+	spot := map[string]string{"InstanceType": "m5.xlarge", "Lifecycle": "spot"}
+	assert.InDelta(t, 0.07, c.Rate(ts, 0.07, spot, fleet), 1e-9)
+}
@@ -0,0 +1,100 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+)
+
+// BaseCurrency is the currency all providers price in
+const BaseCurrency = "USD"
+
+// ErrUnknownCurrency is returned when no rate is known for a currency or
+// unit at the requested time
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ExchangeRate is the number of units of a currency per base currency unit
+// from the effective time onwards
+type ExchangeRate struct {
+	Effective time.Time
+	Rate      float64
+}
+
+// ExchangeRates holds effective dated rates by currency or unit name e.g.
+// EUR or an internal credits unit
+type ExchangeRates struct {
+	rates map[string][]ExchangeRate
+}
+
+// NewExchangeRates returns a new ExchangeRates from the given rates by
+// currency
+func NewExchangeRates(rates map[string][]ExchangeRate) *ExchangeRates {
+	for _, list := range rates {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Effective.Before(list[j].Effective)
+		})
+	}
+	return &ExchangeRates{rates: rates}
+}
+
+// LoadExchangeRates loads rates from the json file at the given path. The
+// file holds a list of effective dated rates keyed by currency
+func LoadExchangeRates(path string) (*ExchangeRates, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var rates map[string][]ExchangeRate
+	if err = json.NewDecoder(fh).Decode(&rates); err == nil {
+		return NewExchangeRates(rates), nil
+	}
+	return nil, err
+}
+
+// Rate returns the rate for the currency in effect at the given time. The
+// base currency always has a rate of 1
+func (er *ExchangeRates) Rate(currency string, at time.Time) (float64, error) {
+	if currency == BaseCurrency {
+		return 1, nil
+	}
+	if er == nil {
+		return 0, ErrUnknownCurrency
+	}
+
+	list := er.rates[currency]
+	// Index of the first rate after at
+	i := sort.Search(len(list), func(i int) bool {
+		return list[i].Effective.After(at)
+	})
+	if i == 0 {
+		return 0, ErrUnknownCurrency
+	}
+	return list[i-1].Rate, nil
+}
+
+// Convert converts the base currency amount to the currency at the given
+// time
+func (er *ExchangeRates) Convert(amount float64, currency string, at time.Time) (float64, error) {
+	rate, err := er.Rate(currency, at)
+	return amount * rate, err
+}
+
+// ConvertSeries converts each base currency price to the currency using the
+// rate in effect at its timestamp
+func (er *ExchangeRates) ConvertSeries(dps tsdb.DataPoints, currency string) (tsdb.DataPoints, error) {
+	out := make(tsdb.DataPoints, len(dps))
+	for i, dp := range dps {
+		v, err := er.Convert(dp.Value, currency, time.Unix(0, int64(dp.Timestamp)))
+		if err != nil {
+			return nil, err
+		}
+		out[i] = tsdb.DataPoint{Timestamp: dp.Timestamp, Value: v}
+	}
+	return out, nil
+}
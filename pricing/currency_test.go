@@ -0,0 +1,47 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExchangeRates(t *testing.T) {
+	jan, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	feb, _ := time.Parse(time.RFC3339, "2019-02-01T00:00:00Z")
+	er := NewExchangeRates(map[string][]ExchangeRate{
+		"EUR": {{Effective: feb, Rate: 0.9}, {Effective: jan, Rate: 0.8}},
+	})
+
+	rate, err := er.Rate("EUR", jan.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 0.8, rate)
+
+	rate, err = er.Rate("EUR", feb)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.9, rate)
+
+	_, err = er.Rate("EUR", jan.Add(-time.Hour))
+	assert.Equal(t, ErrUnknownCurrency, err)
+	_, err = er.Rate("GBP", feb)
+	assert.Equal(t, ErrUnknownCurrency, err)
+
+	rate, err = (*ExchangeRates)(nil).Rate(BaseCurrency, feb)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, rate)
+
+	report := NewReport(tsdb.DataPoints{
+		{Timestamp: uint64(jan.UnixNano()), Value: 1},
+		{Timestamp: uint64(feb.UnixNano()), Value: 1},
+	}, nil)
+	report.SystemOverhead = report.Total / 4
+	converted, err := report.Convert(er, "EUR")
+	assert.Nil(t, err)
+	assert.Equal(t, "EUR", converted.Currency)
+	assert.Equal(t, 0.8, converted.History[0].Value)
+	assert.Equal(t, 0.9, converted.History[1].Value)
+	assert.InDelta(t, report.Total*0.8, converted.Total, 1e-9)
+	assert.InDelta(t, converted.Total/4, converted.SystemOverhead, 1e-9)
+}
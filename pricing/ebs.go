@@ -0,0 +1,77 @@
+package pricing
+
+import (
+	"errors"
+
+	"github.com/euforia/metermaid/node"
+)
+
+// hoursPerMonth is the number of hours aws uses to prorate monthly storage
+// prices
+const hoursPerMonth = 730
+
+// StorageProvider implements an interface to price block storage volumes
+type StorageProvider interface {
+	Name() string
+	// VolumeHourly returns the hourly price of the volume
+	VolumeHourly(vol node.Volume, filter map[string]string) (float64, error)
+}
+
+// EBSRate holds the monthly prices of an ebs volume type
+type EBSRate struct {
+	// Price per GiB-month
+	GBMonth float64
+	// Price per provisioned IOPS-month above FreeIOPS
+	IOPSMonth float64
+	FreeIOPS  int64
+}
+
+// Hourly returns the hourly price of the given volume
+func (rate EBSRate) Hourly(vol node.Volume) float64 {
+	monthly := rate.GBMonth * float64(vol.Size)
+	if vol.IOPS > rate.FreeIOPS {
+		monthly += rate.IOPSMonth * float64(vol.IOPS-rate.FreeIOPS)
+	}
+	return monthly / hoursPerMonth
+}
+
+// DefaultEBSRates are the us-east-1 list prices by volume type.  gp3
+// throughput above the baseline is not priced as the ec2 api version used
+// does not report it
+var DefaultEBSRates = map[string]EBSRate{
+	"gp2":      {GBMonth: 0.10},
+	"gp3":      {GBMonth: 0.08, IOPSMonth: 0.005, FreeIOPS: 3000},
+	"io1":      {GBMonth: 0.125, IOPSMonth: 0.065},
+	"io2":      {GBMonth: 0.125, IOPSMonth: 0.065},
+	"st1":      {GBMonth: 0.045},
+	"sc1":      {GBMonth: 0.015},
+	"standard": {GBMonth: 0.05},
+}
+
+// EBSPricer prices ebs volumes from a rate table
+type EBSPricer struct {
+	rates map[string]EBSRate
+}
+
+// NewEBSPricer returns a new EBSPricer using the given rates by volume
+// type. DefaultEBSRates are used if rates is nil.
+func NewEBSPricer(rates map[string]EBSRate) *EBSPricer {
+	if rates == nil {
+		rates = DefaultEBSRates
+	}
+	return &EBSPricer{rates: rates}
+}
+
+// Name returns the name of the pricer
+func (pp *EBSPricer) Name() string {
+	return "aws-ebs"
+}
+
+// VolumeHourly satisfies the StorageProvider interface
+func (pp *EBSPricer) VolumeHourly(vol node.Volume, filter map[string]string) (float64, error) {
+	rate, ok := pp.rates[vol.Type]
+	if !ok {
+		return 0, errors.New("unknown volume type: " + vol.Type)
+	}
+	return rate.Hourly(vol), nil
+}
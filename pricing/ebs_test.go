@@ -0,0 +1,24 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/euforia/metermaid/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EBSPricer(t *testing.T) {
+	p := NewEBSPricer(nil)
+
+	hourly, err := p.VolumeHourly(node.Volume{Type: "gp3", Size: 100, IOPS: 3000}, nil)
+	assert.Nil(t, err)
+	assert.InDelta(t, 8.0/hoursPerMonth, hourly, 1e-9)
+
+	// Provisioned iops above the baseline
+	hourly, err = p.VolumeHourly(node.Volume{Type: "gp3", Size: 100, IOPS: 4000}, nil)
+	assert.Nil(t, err)
+	assert.InDelta(t, (8.0+5)/hoursPerMonth, hourly, 1e-9)
+
+	_, err = p.VolumeHourly(node.Volume{Type: "unknown"}, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,146 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/tsdb"
+)
+
+// fileCacheKeys are the filter keys that identify the prices of a node
+var fileCacheKeys = []string{node.CloudTag, "Region", "InstanceType", node.LifecycleTag}
+
+// FileCache keeps the prices previously fetched from a provider in a file
+// so they can be served when the provider cannot be reached e.g. after a
+// restart without network access.  It is meant to follow the provider it
+// records in a chain at ConfidenceMedium
+type FileCache struct {
+	path string
+
+	mu     sync.Mutex
+	prices map[string]tsdb.DataPoints
+}
+
+// NewFileCache returns a new FileCache persisted to the json file at the
+// given path.  The file is created on the first recorded prices if it does
+// not exist
+func NewFileCache(path string) (*FileCache, error) {
+	fc := &FileCache{path: path, prices: make(map[string]tsdb.DataPoints)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fc, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &fc.prices); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// Name returns the name of the pricer
+func (fc *FileCache) Name() string {
+	return "file-cache"
+}
+
+// History returns the cached prices from start to end.  The price in effect
+// at start is returned at start
+func (fc *FileCache) History(start, end time.Time, filter map[string]string) (tsdb.DataPoints, error) {
+	s, e := uint64(start.UnixNano()), uint64(end.UnixNano())
+
+	fc.mu.Lock()
+	cached := fc.prices[fileCacheKey(filter)]
+	fc.mu.Unlock()
+
+	dps := make(tsdb.DataPoints, 0)
+	for _, dp := range cached {
+		if dp.Timestamp > e {
+			break
+		}
+		if dp.Timestamp <= s {
+			dp.Timestamp = s
+			dps = dps[:0]
+		}
+		dps = append(dps, dp)
+	}
+	if len(dps) == 0 {
+		return nil, errors.New("no cached prices")
+	}
+	return dps, nil
+}
+
+// Record returns a provider that stores the prices returned by the given
+// provider in the cache
+func (fc *FileCache) Record(pp Provider) Provider {
+	return &recordingProvider{Provider: pp, fc: fc}
+}
+
+func (fc *FileCache) add(filter map[string]string, dps tsdb.DataPoints) error {
+	key := fileCacheKey(filter)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	prices := fc.prices[key].Clone().Insert(dps...)
+	sort.Sort(prices)
+	prices = prices.Dedup()
+	if len(prices) == len(fc.prices[key]) {
+		return nil
+	}
+	fc.prices[key] = prices
+
+	data, err := json.Marshal(fc.prices)
+	if err != nil {
+		return err
+	}
+	// Written in full and renamed so a crash never leaves a partial file
+	tmp, err := ioutil.TempFile(filepath.Dir(fc.path), filepath.Base(fc.path))
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fc.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func fileCacheKey(filter map[string]string) string {
+	parts := make([]string, len(fileCacheKeys))
+	for i, k := range fileCacheKeys {
+		parts[i] = k + "=" + filter[k]
+	}
+	return strings.Join(parts, ",")
+}
+
+// recordingProvider adds the prices of the provider to a FileCache
+type recordingProvider struct {
+	Provider
+	fc *FileCache
+}
+
+// History satisfies the Provider interface.  Failing to write the cache does
+// not fail the request as the prices are still valid
+func (pp *recordingProvider) History(start, end time.Time, filter map[string]string) (tsdb.DataPoints, error) {
+	dps, err := pp.Provider.History(start, end, filter)
+	if err == nil && len(dps) > 0 {
+		pp.fc.add(filter, dps)
+	}
+	return dps, err
+}
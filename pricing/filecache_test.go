@@ -0,0 +1,68 @@
+package pricing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSeriesPricer struct {
+	dps tsdb.DataPoints
+}
+
+func (pp *testSeriesPricer) Name() string { return "series" }
+
+func (pp *testSeriesPricer) History(start, end time.Time, filter map[string]string) (tsdb.DataPoints, error) {
+	return pp.dps, nil
+}
+
+func Test_FileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filecache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "prices.json")
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := func(h int) uint64 { return uint64(t0.Add(time.Duration(h) * time.Hour).UnixNano()) }
+	filter := map[string]string{"InstanceType": "m5.large", "Region": "us-west-2", "team": "infra"}
+
+	cache, err := NewFileCache(path)
+	assert.Nil(t, err)
+	_, err = cache.History(t0, t0, filter)
+	assert.NotNil(t, err)
+
+	// Prices from the provider are recorded under its name
+	primary := &testSeriesPricer{dps: tsdb.DataPoints{{Timestamp: ts(0), Value: 0.1}, {Timestamp: ts(2), Value: 0.2}}}
+	rec := cache.Record(primary)
+	assert.Equal(t, "series", rec.Name())
+	dps, err := rec.History(t0, t0.Add(3*time.Hour), filter)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(dps))
+
+	// Served from the file once the provider is gone.  Tags do not matter
+	chain := NewChainProvider(
+		ChainLink{Provider: &testFailPricer{}, Confidence: ConfidenceHigh},
+		ChainLink{Provider: mustFileCache(t, path), Confidence: ConfidenceMedium},
+	)
+	filter["team"] = "data"
+	dps, src, err := chain.SourcedHistory(t0.Add(time.Hour), t0.Add(3*time.Hour), filter)
+	assert.Nil(t, err)
+	assert.Equal(t, ConfidenceMedium, src.Confidence)
+	assert.Equal(t, tsdb.DataPoints{{Timestamp: ts(1), Value: 0.1}, {Timestamp: ts(2), Value: 0.2}}, dps)
+
+	// Other hardware is not served
+	filter["InstanceType"] = "c5.large"
+	_, _, err = chain.SourcedHistory(t0, t0.Add(3*time.Hour), filter)
+	assert.NotNil(t, err)
+}
+
+func mustFileCache(t *testing.T, path string) *FileCache {
+	fc, err := NewFileCache(path)
+	assert.Nil(t, err)
+	return fc
+}
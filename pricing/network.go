@@ -0,0 +1,92 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	// EgressInterAZ is traffic to another availability zone in the region
+	EgressInterAZ = "inter-az"
+	// EgressInterRegion is traffic to another region
+	EgressInterRegion = "inter-region"
+	// EgressInternet is traffic out to the internet
+	EgressInternet = "internet"
+)
+
+// EgressRates holds the price per GB of data sent out of a node by
+// destination class.  The destination of container traffic is not known so
+// each container is priced by the class it declares or the default
+type EgressRates struct {
+	InterAZ     float64
+	InterRegion float64
+	Internet    float64
+	// Class or per GB rate used for containers that do not declare one
+	Default string
+}
+
+// DefaultEgressRates are the aws list prices
+var DefaultEgressRates = EgressRates{
+	InterAZ:     0.01,
+	InterRegion: 0.02,
+	Internet:    0.09,
+	Default:     EgressInterAZ,
+}
+
+// LoadEgressRates loads egress rates from the json file at the given path
+func LoadEgressRates(path string) (*EgressRates, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	rates := DefaultEgressRates
+	if err = json.NewDecoder(fh).Decode(&rates); err != nil {
+		return nil, err
+	}
+	if err = rates.SetDefault(rates.Default); err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
+
+// SetDefault sets the class or per GB rate of containers that do not
+// declare one
+func (rates *EgressRates) SetDefault(class string) error {
+	if _, ok := rates.rate(class); !ok {
+		return fmt.Errorf("unknown egress class: %q", class)
+	}
+	rates.Default = class
+	return nil
+}
+
+// Rate returns the price per GB for the given class or per GB rate e.g.
+// 0.05, falling back to the default if empty or unknown
+func (rates *EgressRates) Rate(class string) float64 {
+	if rate, ok := rates.rate(class); ok {
+		return rate
+	}
+	rate, _ := rates.rate(rates.Default)
+	return rate
+}
+
+func (rates *EgressRates) rate(class string) (float64, bool) {
+	switch class {
+	case EgressInterAZ:
+		return rates.InterAZ, true
+	case EgressInterRegion:
+		return rates.InterRegion, true
+	case EgressInternet:
+		return rates.Internet, true
+	}
+	rate, err := strconv.ParseFloat(class, 64)
+	return rate, err == nil && rate >= 0
+}
+
+// Egress returns the price of sending the given number of bytes
+func (rates *EgressRates) Egress(class string, bytes float64) float64 {
+	return rates.Rate(class) * bytes / 1e9
+}
@@ -0,0 +1,24 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EgressRates(t *testing.T) {
+	rates := DefaultEgressRates
+	assert.Equal(t, 0.09, rates.Rate(EgressInternet))
+	assert.Equal(t, 0.05, rates.Rate("0.05"))
+	// Undeclared and unknown classes take the default
+	assert.Equal(t, 0.01, rates.Rate(""))
+	assert.Equal(t, 0.01, rates.Rate("moon"))
+
+	assert.Nil(t, rates.SetDefault("0"))
+	assert.Equal(t, 0.0, rates.Rate(""))
+	assert.Equal(t, 0.02, rates.Rate(EgressInterRegion))
+	assert.NotNil(t, rates.SetDefault("moon"))
+	assert.NotNil(t, rates.SetDefault("-1"))
+	assert.Equal(t, "0", rates.Default)
+	assert.Equal(t, EgressInterAZ, DefaultEgressRates.Default)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/euforia/metermaid/node"
 	"github.com/euforia/metermaid/tsdb"
+	"github.com/euforia/metermaid/types"
 )
 
 // Provider implments an interface to return pricing information
@@ -26,6 +27,11 @@ type Pricer struct {
 	mu          sync.RWMutex
 	cache       tsdb.DataPoints
 	lastFetched uint64
+	// Provenance of each cached price by timestamp
+	sources map[uint64]Source
+
+	// Purchased commitments applied to list prices
+	commits *Commitments
 
 	log *zap.Logger
 }
@@ -33,12 +39,23 @@ type Pricer struct {
 // NewPricer returns a new Pricer backed by the given provider
 func NewPricer(provider Provider, nd node.Node, logger *zap.Logger) *Pricer {
 	pr := &Pricer{
-		pp:   provider,
-		node: nd,
-		log:  logger,
+		pp:      provider,
+		node:    nd,
+		sources: make(map[uint64]Source),
+		log:     logger,
 	}
 	start := time.Unix(0, int64(nd.BootTime))
-	pr.fetchHistory(start, start, time.Now())
+	_, err := pr.fetchHistory(start, start, time.Now())
+	if err != nil || len(pr.cache) == 0 {
+		// The cache is filled on the next history request
+		logger.Info("pricer",
+			zap.String("backend", pr.pp.Name()),
+			zap.Int("cache.size", 0),
+			zap.Error(err),
+		)
+		return pr
+	}
+
 	logger.Info("pricer",
 		zap.String("backend", pr.pp.Name()),
 		zap.Time("cache.start", time.Unix(0, int64(pr.cache[0].Timestamp))),
@@ -47,6 +64,65 @@ func NewPricer(provider Provider, nd node.Node, logger *zap.Logger) *Pricer {
 	return pr
 }
 
+// CachedPrice is a cached price with its provenance
+type CachedPrice struct {
+	Timestamp uint64
+	Value     float64
+	Source    Source
+}
+
+// Cached returns a copy of the cached prices in time order
+func (pr *Pricer) Cached() []CachedPrice {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	out := make([]CachedPrice, len(pr.cache))
+	for i, p := range pr.cache {
+		out[i] = CachedPrice{Timestamp: p.Timestamp, Value: p.Value, Source: pr.sources[p.Timestamp]}
+	}
+	return out
+}
+
+// Restore merges previously cached prices into the cache.  Newer prices are
+// still fetched from the provider on the next request
+func (pr *Pricer) Restore(prices []CachedPrice) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	dps := make(tsdb.DataPoints, len(prices))
+	for i, p := range prices {
+		dps[i] = tsdb.DataPoint{Timestamp: p.Timestamp, Value: p.Value}
+		if _, ok := pr.sources[p.Timestamp]; !ok {
+			pr.sources[p.Timestamp] = p.Source
+		}
+	}
+	dps = pr.cache.Insert(dps...)
+	sort.Sort(dps)
+	pr.cache = dps.Dedup()
+}
+
+// SetMeta sets the node metadata used to look up prices e.g. when the
+// instance is retagged.  Cached prices are kept
+func (pr *Pricer) SetMeta(meta types.Meta) {
+	pr.mu.Lock()
+	pr.node.Meta = meta
+	pr.mu.Unlock()
+}
+
+func (pr *Pricer) meta() types.Meta {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+	return pr.node.Meta
+}
+
+// SetCommitments sets the reservations and savings plans used to compute
+// the effective amortized rate of the node
+func (pr *Pricer) SetCommitments(c *Commitments) {
+	pr.mu.Lock()
+	pr.commits = c
+	pr.mu.Unlock()
+}
+
 // History satisfies the Provider interface
 func (pr *Pricer) History(start, end time.Time) (tsdb.DataPoints, error) {
 	pr.log.Debug("price history request", zap.Time("start", start), zap.Time("end", end))
@@ -64,8 +140,16 @@ func (pr *Pricer) History(start, end time.Time) (tsdb.DataPoints, error) {
 		zap.Time("start", start), zap.Time("end", end), zap.Int("count", len(prices)))
 
 	if len(prices) > 0 {
-		// Add end marker for proper price calculation
 		e := uint64(end.UnixNano())
+
+		pr.mu.RLock()
+		commits := pr.commits
+		pr.mu.RUnlock()
+		if commits != nil {
+			prices = commits.Apply(prices, pr.meta(), e)
+		}
+
+		// Add end marker for proper price calculation
 		if last := prices.Last(); last.Timestamp < e {
 			prices = prices.Insert(tsdb.DataPoint{
 				Timestamp: e, Value: last.Value,
@@ -88,22 +172,61 @@ func (pr *Pricer) history(start, end time.Time) (tsdb.DataPoints, error) {
 		return prices, nil
 	}
 
+	if len(pr.cache) == 0 {
+		pr.mu.RUnlock()
+		return pr.fetchHistory(start, start, end)
+	}
+
 	last := pr.cache.Last()
 	pr.mu.RUnlock()
 	return pr.fetchHistory(start, time.Unix(0, int64(last.Timestamp)), end)
 }
 
+// Sources returns the provenance of each of the given prices i.e. the source
+// of the cached price in effect at the time of each price.
+func (pr *Pricer) Sources(prices tsdb.DataPoints) Sources {
+	out := make(Sources, len(prices))
+
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	for i, p := range prices {
+		// Index of the first cached price after p
+		j := sort.Search(len(pr.cache), func(k int) bool {
+			return pr.cache[k].Timestamp > p.Timestamp
+		})
+		if j > 0 {
+			out[i] = pr.sources[pr.cache[j-1].Timestamp]
+		}
+	}
+	return out
+}
+
 // reqStart is the request start time. start is the start of the fetch. reqStart is used
 // to return the query response to avoid an addtional lock/unlock cycle
 func (pr *Pricer) fetchHistory(reqStart, start, end time.Time) (tsdb.DataPoints, error) {
 
-	prices, err := pr.pp.History(start, end, pr.node.Meta)
+	var (
+		prices tsdb.DataPoints
+		src    Source
+		err    error
+	)
+	if spp, ok := pr.pp.(SourcedProvider); ok {
+		prices, src, err = spp.SourcedHistory(start, end, pr.meta())
+	} else {
+		prices, err = pr.pp.History(start, end, pr.meta())
+		src = Source{Provider: pr.pp.Name(), Confidence: ConfidenceHigh}
+	}
+
 	if err == nil {
 		pr.log.Debug("fetched price history",
 			zap.Time("start", start), zap.Time("end", end),
 			zap.Int("count", len(prices)))
 
 		pr.mu.Lock()
+		for _, p := range prices {
+			pr.sources[p.Timestamp] = src
+		}
 		prices = pr.cache.Insert(prices...)
 		sort.Sort(prices)
 		pr.cache = prices.Dedup()
@@ -1,24 +1,82 @@
 package pricing
 
-import "github.com/euforia/metermaid/tsdb"
+import (
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+)
 
 // Report is a price report
 type Report struct {
+	// Currency or unit of all prices in the report
+	Currency string
+
 	Total   float64
 	Min     float64
 	Max     float64
 	Average float64
 
 	History tsdb.DataPoints
+	// Provenance of each price in History
+	Sources Sources
+	// Lowest confidence of all prices in the report
+	Confidence Confidence
+
+	// Portion of Total for the capacity reserved for the os, runtime and
+	// agents.  Containers are priced from the remaining allocatable capacity
+	SystemOverhead float64
+
+	// Total price of volumes attached to the node
+	Storage float64
+	// Portion of Storage not mounted by any container
+	UnallocatedStorage float64
+
+	// Estimated energy used by the node
+	EnergyKWh float64
+	// Estimated grams of CO2 equivalent emitted by the node
+	CarbonCO2e float64
 }
 
-// NewReport returns a new Price computing the per interval and total
-func NewReport(data tsdb.DataPoints) *Report {
-	out := &Report{History: data}
+// NewReport returns a new Price computing the per interval and total. sources
+// is the provenance of each data point
+func NewReport(data tsdb.DataPoints, sources Sources) *Report {
+	out := &Report{Currency: BaseCurrency, History: data, Sources: sources}
 	out.Total = data.SumPerHour()
 	out.Min = data.Min()
 	out.Max = data.Max()
 	out.Average = data.Sum() / float64(len(data))
+	out.Confidence = sources.Confidence()
 
 	return out
 }
+
+// Convert returns a copy of the report in the given currency. History is
+// converted using the rates in effect at each price and storage using the
+// rate at the end of the report.
+func (r *Report) Convert(er *ExchangeRates, currency string) (*Report, error) {
+	if currency == r.Currency {
+		return r, nil
+	}
+
+	history, err := er.ConvertSeries(r.History, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	out := NewReport(history, r.Sources)
+	out.Currency = currency
+	out.EnergyKWh, out.CarbonCO2e = r.EnergyKWh, r.CarbonCO2e
+	if r.Total > 0 {
+		out.SystemOverhead = out.Total * r.SystemOverhead / r.Total
+	}
+
+	var end time.Time
+	if len(r.History) > 0 {
+		end = time.Unix(0, int64(r.History.Last().Timestamp))
+	}
+	if out.Storage, err = er.Convert(r.Storage, currency, end); err != nil {
+		return nil, err
+	}
+	out.UnallocatedStorage, err = er.Convert(r.UnallocatedStorage, currency, end)
+	return out, err
+}
@@ -0,0 +1,54 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/euforia/metermaid/tsdb"
+)
+
+// StaticPricer provides a fixed hourly price per instance type. It is meant
+// as a last resort in a chain when no api is reachable.
+type StaticPricer struct {
+	table map[string]float64
+}
+
+// NewStaticPricer returns a new StaticPricer using the given instance type to
+// hourly price table
+func NewStaticPricer(table map[string]float64) *StaticPricer {
+	return &StaticPricer{table: table}
+}
+
+// LoadStaticPricer loads the instance type to hourly price table from the
+// json file at the given path
+func LoadStaticPricer(path string) (*StaticPricer, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var table map[string]float64
+	if err = json.NewDecoder(fh).Decode(&table); err == nil {
+		return NewStaticPricer(table), nil
+	}
+	return nil, err
+}
+
+// Name returns the name of the pricer
+func (pp *StaticPricer) Name() string {
+	return "static"
+}
+
+// History returns a single price at start for the InstanceType in the filter
+func (pp *StaticPricer) History(start, end time.Time, filter map[string]string) (tsdb.DataPoints, error) {
+	price, ok := pp.table[filter["InstanceType"]]
+	if !ok {
+		return nil, errors.New("instance type not in table")
+	}
+	return tsdb.DataPoints{
+		tsdb.DataPoint{Timestamp: uint64(start.UnixNano()), Value: price},
+	}, nil
+}
@@ -0,0 +1,40 @@
+package metermaid
+
+import (
+	"time"
+
+	"github.com/euforia/metermaid/storage"
+	"go.uber.org/zap"
+)
+
+// retentionInterval is how often destroyed containers are compacted
+const retentionInterval = time.Hour
+
+// runRetention periodically compacts destroyed containers outside the
+// policy if the container store supports it.  The usage series of
+// compacted containers are deleted with them
+func (mm *meterMaid) runRetention(policy storage.RetentionPolicy) {
+	compactor, ok := mm.cstore.(storage.Compactor)
+	if !ok {
+		mm.log.Info("container storage does not support retention")
+		return
+	}
+
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		ids, err := compactor.Compact(policy, now)
+		if err != nil {
+			mm.log.Info("failed to compact containers", zap.Error(err))
+			continue
+		}
+		for _, id := range ids {
+			if _, err = mm.series.Delete(storage.SeriesName(id, "")); err != nil {
+				mm.log.Info("failed to delete series", zap.String("id", id), zap.Error(err))
+			}
+		}
+		if len(ids) > 0 {
+			mm.log.Info("compacted containers", zap.Int("count", len(ids)))
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package snapshot implements export and import of the agent state so its
+// container history survives replacing the host.
+//
+// A snapshot is a stream of json lines.  The first line is the header, each
+// following line is a single record and the last is a trailer holding the
+// record counts and the hex sha256 of all preceding bytes.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/pricing"
+	"github.com/euforia/metermaid/storage"
+	"github.com/euforia/metermaid/types"
+)
+
+// Format identifies a snapshot stream
+const Format = "metermaid.snapshot"
+
+// Version is the current snapshot version.  Snapshots with a newer version
+// are rejected
+const Version = 1
+
+// Record kinds
+const (
+	KindContainer = "container"
+	KindAggregate = "aggregate"
+	KindPrice     = "price"
+	KindNode      = "node"
+	KindEnd       = "end"
+)
+
+var (
+	// ErrFormat is returned when the stream is not a snapshot
+	ErrFormat = errors.New("not a snapshot")
+	// ErrChecksum is returned when the snapshot is corrupt or truncated
+	ErrChecksum = errors.New("snapshot checksum mismatch")
+)
+
+// Header is the first line of a snapshot
+type Header struct {
+	Format  string
+	Version int
+	Created time.Time
+	// Node the snapshot was taken on
+	Node node.Node
+}
+
+// Record is a single line of a snapshot.  Only the field of the kind is set
+type Record struct {
+	Kind      string
+	Container *types.Container        `json:",omitempty"`
+	Aggregate *storage.DailyAggregate `json:",omitempty"`
+	Price     *pricing.CachedPrice    `json:",omitempty"`
+	Node      *node.Node              `json:",omitempty"`
+	// Set on the end record
+	Counts map[string]int `json:",omitempty"`
+	SHA256 string         `json:",omitempty"`
+}
+
+// State is the agent state covered by a snapshot.  Nil fields are skipped
+type State struct {
+	// Local node.  Called when exporting or restoring as the node may be
+	// rediscovered in between
+	Node       func() *node.Node
+	Containers storage.Containers
+	Prices     *pricing.Pricer
+	// Node registry
+	Nodes storage.Nodes
+}
+
+// Snapshot is a verified snapshot read into memory
+type Snapshot struct {
+	Header
+	Containers []types.Container
+	Aggregates []storage.DailyAggregate
+	Prices     []pricing.CachedPrice
+	Nodes      []node.Node
+}
+
+type writer struct {
+	enc    *json.Encoder
+	counts map[string]int
+}
+
+func (wr *writer) write(rec Record) error {
+	wr.counts[rec.Kind]++
+	return wr.enc.Encode(rec)
+}
+
+// Export streams a snapshot of the state to w
+func Export(w io.Writer, st State) error {
+	h := sha256.New()
+	wr := &writer{
+		enc:    json.NewEncoder(io.MultiWriter(w, h)),
+		counts: make(map[string]int),
+	}
+
+	header := Header{Format: Format, Version: Version, Created: time.Now().UTC()}
+	if st.Node != nil {
+		header.Node = *st.Node()
+	}
+	if err := wr.enc.Encode(header); err != nil {
+		return err
+	}
+
+	if st.Containers != nil {
+		err := st.Containers.Iter(func(c types.Container) error {
+			return wr.write(Record{Kind: KindContainer, Container: &c})
+		})
+		if err != nil {
+			return err
+		}
+		if compactor, ok := st.Containers.(storage.Compactor); ok {
+			err = compactor.Aggregates(func(agg storage.DailyAggregate) error {
+				return wr.write(Record{Kind: KindAggregate, Aggregate: &agg})
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if st.Prices != nil {
+		for _, p := range st.Prices.Cached() {
+			p := p
+			if err := wr.write(Record{Kind: KindPrice, Price: &p}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if st.Nodes != nil {
+		err := st.Nodes.Iter(func(n node.Node) error {
+			return wr.write(Record{Kind: KindNode, Node: &n})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	end := Record{Kind: KindEnd, Counts: wr.counts, SHA256: hex.EncodeToString(h.Sum(nil))}
+	return json.NewEncoder(w).Encode(end)
+}
+
+// Read reads and verifies a snapshot.  Nothing is returned unless the
+// version is supported, the checksum matches and all records are present
+func Read(r io.Reader) (*Snapshot, error) {
+	var (
+		br     = bufio.NewReader(r)
+		h      = sha256.New()
+		snap   = &Snapshot{}
+		counts = make(map[string]int)
+	)
+
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, ErrFormat
+	}
+	if err = json.Unmarshal(line, &snap.Header); err != nil || snap.Format != Format {
+		return nil, ErrFormat
+	}
+	if snap.Version > Version {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+	h.Write(line)
+
+	for {
+		line, err = br.ReadBytes('\n')
+		if err == io.EOF && len(bytes.TrimSpace(line)) == 0 {
+			// No end record
+			return nil, ErrChecksum
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		var rec Record
+		if err = json.Unmarshal(line, &rec); err != nil {
+			return nil, ErrChecksum
+		}
+
+		switch {
+		case rec.Kind == KindContainer && rec.Container != nil:
+			snap.Containers = append(snap.Containers, *rec.Container)
+		case rec.Kind == KindAggregate && rec.Aggregate != nil:
+			snap.Aggregates = append(snap.Aggregates, *rec.Aggregate)
+		case rec.Kind == KindPrice && rec.Price != nil:
+			snap.Prices = append(snap.Prices, *rec.Price)
+		case rec.Kind == KindNode && rec.Node != nil:
+			snap.Nodes = append(snap.Nodes, *rec.Node)
+		case rec.Kind == KindEnd:
+			if rec.SHA256 != hex.EncodeToString(h.Sum(nil)) || len(rec.Counts) != len(counts) {
+				return nil, ErrChecksum
+			}
+			for kind, n := range rec.Counts {
+				if counts[kind] != n {
+					return nil, ErrChecksum
+				}
+			}
+			return snap, nil
+		default:
+			return nil, fmt.Errorf("invalid snapshot record: %s", rec.Kind)
+		}
+		counts[rec.Kind]++
+		h.Write(line)
+	}
+}
+
+// Restore loads the snapshot into the state.  Containers still running
+// when the snapshot was taken are stopped and destroyed at that time as
+// they did not move with it.  Restoring again replaces rather than adds
+// to the aggregates.  Cached prices are only
+// restored if the snapshot node has the same instance type and region as
+// the local node so they are not applied to different hardware.  Nodes are
+// not restored as the registry is built by gossip.  It returns false for
+// prices if they were skipped
+func (snap *Snapshot) Restore(st State) (prices bool, err error) {
+	if st.Containers != nil {
+		created := snap.Created.UnixNano()
+		for _, c := range snap.Containers {
+			if !c.Destroyed() {
+				if c.Stop == 0 {
+					c.Stop = created
+				}
+				c.Destroy = created
+			}
+			if err = st.Containers.Set(c); err != nil {
+				return false, err
+			}
+		}
+		if compactor, ok := st.Containers.(storage.Compactor); ok {
+			for _, agg := range snap.Aggregates {
+				if err = compactor.SetAggregate(agg); err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+
+	if st.Prices != nil && st.Node != nil && samePricing(snap.Node, *st.Node()) {
+		st.Prices.Restore(snap.Prices)
+		prices = true
+	}
+	return prices, nil
+}
+
+// Addresses returns the addresses of the nodes in the snapshot excluding
+// the node it was taken on.  These can be used to rejoin the cluster
+func (snap *Snapshot) Addresses() []string {
+	addrs := make([]string, 0, len(snap.Nodes))
+	for _, n := range snap.Nodes {
+		if n.Address != "" && n.Address != snap.Node.Address {
+			addrs = append(addrs, n.Address)
+		}
+	}
+	return addrs
+}
+
+func samePricing(a, b node.Node) bool {
+	for _, key := range []string{"InstanceType", "Region", node.CloudTag} {
+		if a.Meta[key] != b.Meta[key] {
+			return false
+		}
+	}
+	return true
+}
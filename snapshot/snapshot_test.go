@@ -0,0 +1,96 @@
+package snapshot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/pricing"
+	"github.com/euforia/metermaid/storage"
+	"github.com/euforia/metermaid/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func testState(t *testing.T, instanceType string) State {
+	nd := &node.Node{
+		Name:     "n1",
+		Address:  "10.0.0.1:8080",
+		BootTime: uint64(time.Now().Add(-time.Hour).UnixNano()),
+		Meta:     types.Meta{"InstanceType": instanceType, "Region": "us-west-2"},
+	}
+	pp := pricing.NewStaticPricer(map[string]float64{"m5.large": 0.096, "c5.large": 0.085})
+	return State{
+		Node:       func() *node.Node { return nd },
+		Containers: storage.NewInmemContainers(),
+		Prices:     pricing.NewPricer(pp, *nd, zap.NewNop()),
+	}
+}
+
+func Test_ExportRestore(t *testing.T) {
+	src := testState(t, "m5.large")
+	src.Containers.Set(types.Container{ID: "a", Labels: map[string]string{"service": "api"}, UnitsBurned: 1})
+	src.Containers.Set(types.Container{ID: "b", Destroy: time.Now().UnixNano(), UnitsBurned: 2})
+	src.Containers.(storage.Compactor).Compact(storage.RetentionPolicy{MaxAge: time.Nanosecond}, time.Now().Add(time.Second))
+
+	var buf bytes.Buffer
+	assert.Nil(t, Export(&buf, src))
+
+	snap, err := Read(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, Version, snap.Version)
+	assert.Equal(t, "n1", snap.Node.Name)
+	assert.Equal(t, 1, len(snap.Containers))
+	assert.Equal(t, 1, len(snap.Aggregates))
+	assert.NotEmpty(t, snap.Prices)
+
+	dst := testState(t, "m5.large")
+	prices, err := snap.Restore(dst)
+	assert.Nil(t, err)
+	assert.True(t, prices)
+	c, err := dst.Containers.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, c.UnitsBurned)
+	// Running containers end when the snapshot was taken
+	assert.Equal(t, snap.Created.UnixNano(), c.Stop)
+	assert.Equal(t, snap.Created.UnixNano(), c.Destroy)
+
+	// Restoring again does not add to the aggregates
+	_, err = snap.Restore(dst)
+	assert.Nil(t, err)
+	var aggs []storage.DailyAggregate
+	dst.Containers.(storage.Compactor).Aggregates(func(agg storage.DailyAggregate) error {
+		aggs = append(aggs, agg)
+		return nil
+	})
+	assert.Equal(t, 1, len(aggs))
+	assert.Equal(t, 2.0, aggs[0].UnitsBurned)
+	assert.Equal(t, src.Prices.Cached()[0].Source, dst.Prices.Cached()[0].Source)
+
+	// Prices are not restored onto different hardware
+	prices, _ = snap.Restore(testState(t, "c5.large"))
+	assert.False(t, prices)
+}
+
+func Test_Read_Integrity(t *testing.T) {
+	src := testState(t, "m5.large")
+	src.Containers.Set(types.Container{ID: "a", UnitsBurned: 1})
+	var buf bytes.Buffer
+	Export(&buf, src)
+	data := buf.String()
+
+	_, err := Read(strings.NewReader(strings.Replace(data, `"UnitsBurned":1`, `"UnitsBurned":9`, 1)))
+	assert.Equal(t, ErrChecksum, err)
+
+	lines := strings.SplitAfter(data, "\n")
+	_, err = Read(strings.NewReader(strings.Join(lines[:len(lines)-2], "")))
+	assert.Equal(t, ErrChecksum, err)
+
+	_, err = Read(strings.NewReader(strings.Replace(data, `"Version":1`, `"Version":2`, 1)))
+	assert.NotNil(t, err)
+
+	_, err = Read(strings.NewReader("{}\n"))
+	assert.Equal(t, ErrFormat, err)
+}
@@ -0,0 +1,59 @@
+package metermaid
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/euforia/metermaid/storage"
+	"github.com/euforia/metermaid/tsdb"
+	"github.com/euforia/metermaid/types"
+)
+
+// Series metrics stored per container
+const (
+	// MetricNetRxBytes is the series metric for network bytes received
+	MetricNetRxBytes = "net.rx.bytes"
+	// MetricNetTxBytes is the series metric for network bytes sent
+	MetricNetTxBytes = "net.tx.bytes"
+	// MetricBlkReadBytes is the series metric for block device bytes read
+	MetricBlkReadBytes = "blk.read.bytes"
+	// MetricBlkWriteBytes is the series metric for block device bytes written
+	MetricBlkWriteBytes = "blk.write.bytes"
+	// MetricBlkReadOps is the series metric for block device read operations
+	MetricBlkReadOps = "blk.read.ops"
+	// MetricBlkWriteOps is the series metric for block device write operations
+	MetricBlkWriteOps = "blk.write.ops"
+	// MetricWritableLayerBytes is the series metric for the writable layer size
+	MetricWritableLayerBytes = "disk.rw.bytes"
+)
+
+// runStats stores usage samples as series until the collector closes the
+// channel
+func (mm *meterMaid) runStats(stats <-chan types.ContainerStats) {
+	for s := range stats {
+		ts := uint64(s.Timestamp)
+		for metric, value := range map[string]float64{
+			MetricNetRxBytes:         float64(s.NetRxBytes),
+			MetricNetTxBytes:         float64(s.NetTxBytes),
+			MetricBlkReadBytes:       float64(s.BlkReadBytes),
+			MetricBlkWriteBytes:      float64(s.BlkWriteBytes),
+			MetricBlkReadOps:         float64(s.BlkReadOps),
+			MetricBlkWriteOps:        float64(s.BlkWriteOps),
+			MetricWritableLayerBytes: float64(s.WritableLayerBytes),
+		} {
+			mm.series.Append(storage.SeriesName(s.ID, metric),
+				tsdb.DataPoint{Timestamp: ts, Value: value})
+		}
+
+		mm.log.Debug("stats", zap.String("id", s.ID[:12]))
+	}
+}
+
+// counterIncrease returns the total increase of the container counter
+// series for the given metric
+func (mm *meterMaid) counterIncrease(id, metric string) float64 {
+	s, err := mm.series.Get(storage.SeriesName(id, metric))
+	if err != nil {
+		return 0
+	}
+	return s.Data.Increase()
+}
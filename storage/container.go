@@ -4,6 +4,7 @@ import (
 	"errors"
 	"sync"
 
+	"github.com/euforia/metermaid/fl"
 	"github.com/euforia/metermaid/types"
 )
 
@@ -18,18 +19,33 @@ type Containers interface {
 	Set(types.Container) error
 	List() ([]types.Container, error)
 	Iter(func(types.Container) error) error
+	// Query calls the function with each container matching the expression
+	Query(fl.Expr, func(types.Container) error) error
+	// Watch returns a watch delivering changes to containers matching the
+	// expression from now on
+	Watch(fl.Expr) *Watch
 }
 
 // InmemContainers implements an in memory Containers interface
 type InmemContainers struct {
-	mu sync.RWMutex
-	m  map[string]types.Container
+	mu  sync.RWMutex
+	m   map[string]types.Container
+	idx *containerIndex
+	// Daily totals of compacted containers
+	aggs map[aggKey]*DailyAggregate
+	// Distinct values aggregated by day, label and currency
+	aggValues map[aggKey]int
+
+	watchers watchers
 }
 
 // NewInmemContainers returns a new instance of InmemContainers
 func NewInmemContainers() *InmemContainers {
 	return &InmemContainers{
-		m: make(map[string]types.Container),
+		m:         make(map[string]types.Container),
+		idx:       newContainerIndex(),
+		aggs:      make(map[aggKey]*DailyAggregate),
+		aggValues: make(map[aggKey]int),
 	}
 }
 
@@ -47,8 +63,17 @@ func (store *InmemContainers) Get(id string) (types.Container, error) {
 // Set satisfies the Containers interface
 func (store *InmemContainers) Set(c types.Container) error {
 	store.mu.Lock()
+	var prev *types.Container
+	if p, ok := store.m[c.ID]; ok {
+		prev = &p
+	}
+	store.idx.update(prev, &c)
 	store.m[c.ID] = c
 	store.mu.Unlock()
+
+	if typ, changed := changeType(prev, &c); changed {
+		store.watchers.publish(Event{Type: typ, Container: c}, prev)
+	}
 	return nil
 }
 
@@ -74,3 +99,40 @@ func (store *InmemContainers) Iter(f func(types.Container) error) (err error) {
 	store.mu.RUnlock()
 	return err
 }
+
+// Query satisfies the Containers interface.  Label equality and existence,
+// ID equality and Create and Destroy range filters are answered from the
+// indexes.  Anything else falls back to a full scan
+func (store *InmemContainers) Query(expr fl.Expr, f func(types.Container) error) (err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ids, ok := store.idx.plan(expr)
+	if !ok {
+		for _, c := range store.m {
+			if !expr.Eval(&c) {
+				continue
+			}
+			if err = f(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for id := range ids {
+		c, ok := store.m[id]
+		if !ok || !expr.Eval(&c) {
+			continue
+		}
+		if err = f(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watch satisfies the Containers interface
+func (store *InmemContainers) Watch(expr fl.Expr) *Watch {
+	return store.watchers.add(expr)
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore() *InmemContainers {
+	store := NewInmemContainers()
+	base := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		c := types.Container{
+			ID:     fmt.Sprintf("c%02d", i),
+			Create: base.Add(time.Duration(i) * time.Hour).UnixNano(),
+			Labels: map[string]string{"service": []string{"api", "web", "batch"}[i%3]},
+		}
+		if i%2 == 0 {
+			c.Labels["team"] = "infra"
+		}
+		store.Set(c)
+	}
+	// Updates move the index entries
+	c, _ := store.Get("c00")
+	c.Labels = map[string]string{"service": "web"}
+	c.Destroy = base.Add(48 * time.Hour).UnixNano()
+	store.Set(c)
+	return store
+}
+
+func queryIDs(t *testing.T, store *InmemContainers, q string) (indexed, scanned []string) {
+	expr, err := fl.ParseExpr(q)
+	assert.Nil(t, err)
+
+	store.Query(expr, func(c types.Container) error {
+		indexed = append(indexed, c.ID)
+		return nil
+	})
+	store.Iter(func(c types.Container) error {
+		if expr.Eval(&c) {
+			scanned = append(scanned, c.ID)
+		}
+		return nil
+	})
+	return
+}
+
+func Test_InmemContainers_Query(t *testing.T) {
+	store := testStore()
+
+	for _, q := range []string{
+		"service=api",
+		"service=api,batch AND team=infra",
+		"team=exists: OR service=web",
+		"Create>=2019-03-01T05:00:00Z AND Create<2019-03-01T10:00:00Z",
+		"Destroy>2019-03-02T00:00:00Z",
+		"ID=c03,c04 AND NOT service=web",
+		"service=web AND Name=prefix:x",
+		"NOT team=infra",
+	} {
+		indexed, scanned := queryIDs(t, store, q)
+		assert.ElementsMatch(t, scanned, indexed, q)
+	}
+
+	_, ok := store.idx.plan(mustParse(t, "NOT team=infra"))
+	assert.False(t, ok)
+	ids, ok := store.idx.plan(mustParse(t, "service=api AND Memory>1"))
+	assert.True(t, ok)
+	assert.Equal(t, 6, len(ids))
+	assert.Equal(t, 0, len(store.idx.label("service", []string{"api"}).intersect(idSet{"c00": {}})))
+}
+
+func mustParse(t *testing.T, q string) fl.Expr {
+	expr, err := fl.ParseExpr(q)
+	assert.Nil(t, err)
+	return expr
+}
+
+func Test_InmemContainers_Compact(t *testing.T) {
+	store := testStore()
+	now := time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)
+	base := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, id := range []string{"c01", "c02"} {
+		c, _ := store.Get(id)
+		c.UnitsBurned = 1.5
+		c.Destroy = base.Add(24 * time.Hour).UnixNano()
+		store.Set(c)
+	}
+
+	// Keeps the newest destroyed container and all running ones
+	ids, err := store.Compact(RetentionPolicy{MaxAge: 30 * 24 * time.Hour, MaxCount: 1, AggregateLabels: []string{"service", "team"}}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(ids))
+	_, err = store.Get("c00")
+	assert.Nil(t, err)
+	list, _ := store.List()
+	assert.Equal(t, 18, len(list))
+	assert.Equal(t, 0, len(store.idx.label("service", []string{"web", "batch"}).intersect(idSet{"c01": {}, "c02": {}})))
+
+	// Age based
+	ids, _ = store.Compact(RetentionPolicy{MaxAge: 6 * 24 * time.Hour, AggregateLabels: []string{"service", "team"}}, now)
+	assert.Equal(t, 1, len(ids))
+
+	var aggs []DailyAggregate
+	store.Aggregates(func(agg DailyAggregate) error {
+		aggs = append(aggs, agg)
+		return nil
+	})
+	assert.Equal(t, 6, len(aggs))
+	assert.Equal(t, base.Add(24*time.Hour), aggs[0].Day)
+	assert.Equal(t, "", aggs[0].Label)
+	assert.Equal(t, 2, aggs[0].Containers)
+	assert.Equal(t, 3.0, aggs[0].UnitsBurned)
+	assert.Equal(t, "service", aggs[1].Label)
+	assert.Equal(t, "batch", aggs[1].Value)
+	assert.Equal(t, "team", aggs[3].Label)
+	assert.Equal(t, base.Add(48*time.Hour), aggs[4].Day)
+}
+
+func Test_InmemContainers_Compact_bounded(t *testing.T) {
+	store := NewInmemContainers()
+	day := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		store.Set(types.Container{
+			ID:      fmt.Sprintf("c%04d", i),
+			Labels:  map[string]string{"request": fmt.Sprintf("r%04d", i), "service": fmt.Sprintf("s%d", i%10)},
+			Create:  day.UnixNano(),
+			Destroy: day.UnixNano(),
+		})
+	}
+
+	policy := RetentionPolicy{MaxCount: 1, AggregateLabels: []string{"service"}, MaxLabelValues: 5}
+	ids, _ := store.Compact(policy, day)
+	assert.Equal(t, 999, len(ids))
+
+	// The total, 5 services and the rest.  Unlisted labels are not kept
+	counts := make(map[string]int)
+	services := 0
+	store.Aggregates(func(agg DailyAggregate) error {
+		assert.NotEqual(t, "request", agg.Label)
+		counts[agg.Value] = agg.Containers
+		if agg.Label == "service" {
+			services += agg.Containers
+		}
+		return nil
+	})
+	assert.Equal(t, 7, len(counts))
+	assert.Equal(t, 999, counts[""])
+	assert.Equal(t, 999, services)
+	assert.True(t, counts[OtherValue] >= 4*100)
+
+	// Restored aggregates count towards the limit
+	store = NewInmemContainers()
+	for i := 0; i < 5; i++ {
+		store.SetAggregate(DailyAggregate{Day: day.Truncate(24 * time.Hour), Label: "service", Value: fmt.Sprintf("x%d", i)})
+	}
+	store.Set(types.Container{ID: "c", Labels: map[string]string{"service": "web"}, Destroy: day.UnixNano()})
+	policy.MaxCount, policy.MaxAge = 0, time.Minute
+	ids, _ = store.Compact(policy, day.Add(time.Hour))
+	assert.Equal(t, 1, len(ids))
+	n := 0
+	store.Aggregates(func(agg DailyAggregate) error {
+		n++
+		return nil
+	})
+	assert.Equal(t, 7, n)
+}
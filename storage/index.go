@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/euforia/metermaid/types"
+)
+
+// idSet is a set of container ids
+type idSet map[string]struct{}
+
+func (s idSet) intersect(o idSet) idSet {
+	if len(o) < len(s) {
+		s, o = o, s
+	}
+	out := make(idSet, len(s))
+	for id := range s {
+		if _, ok := o[id]; ok {
+			out[id] = struct{}{}
+		}
+	}
+	return out
+}
+
+func (s idSet) union(o idSet) idSet {
+	out := make(idSet, len(s)+len(o))
+	for id := range s {
+		out[id] = struct{}{}
+	}
+	for id := range o {
+		out[id] = struct{}{}
+	}
+	return out
+}
+
+// timeEntry is an entry in a sorted time index
+type timeEntry struct {
+	ts int64
+	id string
+}
+
+// timeIndex is a list of container ids sorted by a timestamp
+type timeIndex []timeEntry
+
+func (idx timeIndex) search(e timeEntry) int {
+	return sort.Search(len(idx), func(i int) bool {
+		return idx[i].ts > e.ts || (idx[i].ts == e.ts && idx[i].id >= e.id)
+	})
+}
+
+func (idx *timeIndex) insert(ts int64, id string) {
+	e := timeEntry{ts, id}
+	i := idx.search(e)
+	*idx = append(*idx, timeEntry{})
+	copy((*idx)[i+1:], (*idx)[i:])
+	(*idx)[i] = e
+}
+
+func (idx *timeIndex) remove(ts int64, id string) {
+	e := timeEntry{ts, id}
+	i := idx.search(e)
+	if i < len(*idx) && (*idx)[i] == e {
+		*idx = append((*idx)[:i], (*idx)[i+1:]...)
+	}
+}
+
+// between returns the ids with a timestamp in the inclusive range
+func (idx timeIndex) between(min, max int64) idSet {
+	start := sort.Search(len(idx), func(i int) bool { return idx[i].ts >= min })
+	out := make(idSet)
+	for _, e := range idx[start:] {
+		if e.ts > max {
+			break
+		}
+		out[e.id] = struct{}{}
+	}
+	return out
+}
+
+// containerIndex holds the secondary indexes of a container store
+type containerIndex struct {
+	// label key to value to ids
+	labels map[string]map[string]idSet
+	// sorted by time fields
+	times map[string]*timeIndex
+}
+
+// indexed time fields
+var indexedTimes = []string{"Create", "Destroy"}
+
+func newContainerIndex() *containerIndex {
+	idx := &containerIndex{
+		labels: make(map[string]map[string]idSet),
+		times:  make(map[string]*timeIndex, len(indexedTimes)),
+	}
+	for _, field := range indexedTimes {
+		idx.times[field] = &timeIndex{}
+	}
+	return idx
+}
+
+func timeField(c *types.Container, field string) int64 {
+	if field == "Create" {
+		return c.Create
+	}
+	return c.Destroy
+}
+
+// update replaces the index entries of the previous container if any with
+// those of the current one
+func (idx *containerIndex) update(prev *types.Container, c *types.Container) {
+	for k, v := range c.Labels {
+		if prev != nil {
+			if pv, ok := prev.Labels[k]; ok && pv == v {
+				continue
+			}
+		}
+		vals, ok := idx.labels[k]
+		if !ok {
+			vals = make(map[string]idSet)
+			idx.labels[k] = vals
+		}
+		ids, ok := vals[v]
+		if !ok {
+			ids = make(idSet)
+			vals[v] = ids
+		}
+		ids[c.ID] = struct{}{}
+	}
+
+	if prev != nil {
+		for k, pv := range prev.Labels {
+			if v, ok := c.Labels[k]; ok && v == pv {
+				continue
+			}
+			idx.removeLabel(k, pv, c.ID)
+		}
+	}
+
+	for _, field := range indexedTimes {
+		ts := timeField(c, field)
+		if prev != nil {
+			pts := timeField(prev, field)
+			if pts == ts {
+				continue
+			}
+			idx.times[field].remove(pts, c.ID)
+		}
+		idx.times[field].insert(ts, c.ID)
+	}
+}
+
+// remove removes all index entries of the container
+func (idx *containerIndex) remove(c *types.Container) {
+	for k, v := range c.Labels {
+		idx.removeLabel(k, v, c.ID)
+	}
+	for _, field := range indexedTimes {
+		idx.times[field].remove(timeField(c, field), c.ID)
+	}
+}
+
+func (idx *containerIndex) removeLabel(k, v, id string) {
+	vals := idx.labels[k]
+	ids := vals[v]
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(vals, v)
+	}
+	if len(vals) == 0 {
+		delete(idx.labels, k)
+	}
+}
+
+// label returns the ids having the label set to any of the values
+func (idx *containerIndex) label(key string, values []string) idSet {
+	vals := idx.labels[key]
+	out := make(idSet)
+	for _, v := range values {
+		for id := range vals[v] {
+			out[id] = struct{}{}
+		}
+	}
+	return out
+}
+
+// labelExists returns the ids having the label set
+func (idx *containerIndex) labelExists(key string) idSet {
+	out := make(idSet)
+	for _, ids := range idx.labels[key] {
+		for id := range ids {
+			out[id] = struct{}{}
+		}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/types"
+)
+
+// plan returns the candidate ids that may match the expression using the
+// indexes.  ok is false if the expression cannot be answered from the
+// indexes and a full scan is needed.  Candidates are a superset of the
+// matches so each must still be evaluated against the expression
+func (idx *containerIndex) plan(expr fl.Expr) (idSet, bool) {
+	switch e := expr.(type) {
+	case *fl.AndExpr:
+		left, lok := idx.plan(e.Left)
+		right, rok := idx.plan(e.Right)
+		switch {
+		case lok && rok:
+			return left.intersect(right), true
+		case lok:
+			return left, true
+		case rok:
+			return right, true
+		}
+
+	case *fl.OrExpr:
+		left, lok := idx.plan(e.Left)
+		if !lok {
+			return nil, false
+		}
+		right, rok := idx.plan(e.Right)
+		if rok {
+			return left.union(right), true
+		}
+
+	case *fl.FieldExpr:
+		return idx.planField(e.Field, e.Filter)
+	}
+	return nil, false
+}
+
+func (idx *containerIndex) planField(field string, filter fl.Filter) (idSet, bool) {
+	if field == "ID" && filter.Operator == fl.NoOp {
+		out := make(idSet, len(filter.Values))
+		for _, id := range filter.Values {
+			out[id] = struct{}{}
+		}
+		return out, true
+	}
+
+	if ti, ok := idx.times[field]; ok {
+		min, max, ok := timeRange(filter)
+		if !ok {
+			return nil, false
+		}
+		return ti.between(min, max), true
+	}
+
+	if _, dynamic, ok := types.ContainerSchema.Field(field); !ok || !dynamic {
+		return nil, false
+	}
+	key := strings.TrimPrefix(field, types.LabelPrefix)
+	switch filter.Operator {
+	case fl.NoOp:
+		return idx.label(key, filter.Values), true
+	case fl.OpExists:
+		return idx.labelExists(key), true
+	}
+	return nil, false
+}
+
+// timeRange returns the inclusive epoch nano range matching a time
+// comparison.  Values are OR'd so the widest bound is used
+func timeRange(filter fl.Filter) (min, max int64, ok bool) {
+	min, max = math.MinInt64, math.MaxInt64
+	now := time.Now()
+
+	var bounds []int64
+	for _, val := range filter.Values {
+		t, err := fl.ParseTime(val, now)
+		if err != nil {
+			continue
+		}
+		bounds = append(bounds, t.UnixNano())
+	}
+	if len(bounds) == 0 {
+		return 0, 0, false
+	}
+
+	switch filter.Operator {
+	case fl.OpLess, fl.OpLessEqual:
+		max = bounds[0]
+		for _, b := range bounds[1:] {
+			if b > max {
+				max = b
+			}
+		}
+	case fl.OpGreater, fl.OpGreaterEqual:
+		min = bounds[0]
+		for _, b := range bounds[1:] {
+			if b < min {
+				min = b
+			}
+		}
+	default:
+		return 0, 0, false
+	}
+	return min, max, true
+}
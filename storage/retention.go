@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/euforia/metermaid/types"
+)
+
+// RetentionPolicy bounds the destroyed containers kept in a store. Zero
+// disables a limit.  Running containers are always kept
+type RetentionPolicy struct {
+	// Destroyed containers older than this are compacted
+	MaxAge time.Duration
+	// Only the most recently destroyed containers are kept beyond this
+	MaxCount int
+	// Label keys compacted containers are also aggregated by.  Only the
+	// daily totals are kept when empty
+	AggregateLabels []string
+	// Values of a label aggregated per day beyond this are added to the
+	// OtherValue aggregate.  Zero disables the limit
+	MaxLabelValues int
+}
+
+// OtherValue is the value of the aggregate holding the containers of a label
+// past the policy's MaxLabelValues
+const OtherValue = "other"
+
+// DailyAggregate holds the totals of compacted containers by the UTC day
+// they were destroyed and a label.  An empty Label holds the totals of all
+// containers destroyed that day.  Containers are counted once per label so
+// only the empty label sums to the total
+type DailyAggregate struct {
+	Day                time.Time
+	Label              string `json:",omitempty"`
+	Value              string `json:",omitempty"`
+	Currency           string `json:",omitempty"`
+	Containers         int
+	UnitsBurned        float64
+	StorageUnitsBurned float64
+	NetworkUnitsBurned float64
+	EnergyKWh          float64
+	CarbonCO2e         float64
+	RunTime            time.Duration
+	AllocatedTime      time.Duration
+}
+
+func (agg *DailyAggregate) add(c *types.Container) {
+	agg.Containers++
+	agg.UnitsBurned += c.UnitsBurned
+	agg.StorageUnitsBurned += c.StorageUnitsBurned
+	agg.NetworkUnitsBurned += c.NetworkUnitsBurned
+	agg.EnergyKWh += c.EnergyKWh
+	agg.CarbonCO2e += c.CarbonCO2e
+	agg.RunTime += c.RunTime()
+	agg.AllocatedTime += c.AllocatedTime()
+}
+
+type aggKey struct {
+	day          int64
+	label, value string
+	currency     string
+}
+
+// Compactor is implemented by container stores that roll destroyed
+// containers into daily aggregates to stay bounded
+type Compactor interface {
+	// Compact aggregates and removes the destroyed containers outside the
+	// policy returning the ids of those removed
+	Compact(policy RetentionPolicy, now time.Time) ([]string, error)
+	// Aggregates calls the function with each daily aggregate in day order
+	Aggregates(func(DailyAggregate) error) error
+	// SetAggregate replaces the aggregate of the same day, label and
+	// currency e.g. when restoring a snapshot
+	SetAggregate(DailyAggregate) error
+}
+
+// Compact satisfies the Compactor interface
+func (store *InmemContainers) Compact(policy RetentionPolicy, now time.Time) ([]string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	destroyed := make([]types.Container, 0)
+	for _, c := range store.m {
+		if c.Destroyed() {
+			destroyed = append(destroyed, c)
+		}
+	}
+	// Newest first so the oldest are past the count
+	sort.Slice(destroyed, func(i, j int) bool {
+		return destroyed[i].Destroy > destroyed[j].Destroy
+	})
+
+	var removed []string
+	for i := range destroyed {
+		c := &destroyed[i]
+		expired := policy.MaxAge > 0 && now.Sub(time.Unix(0, c.Destroy)) > policy.MaxAge
+		excess := policy.MaxCount > 0 && i >= policy.MaxCount
+		if !expired && !excess {
+			continue
+		}
+		store.aggregate(c, policy)
+		store.idx.remove(c)
+		delete(store.m, c.ID)
+		removed = append(removed, c.ID)
+	}
+	return removed, nil
+}
+
+// aggregate adds the container to the totals of its destroy day and the
+// policy's aggregate labels
+func (store *InmemContainers) aggregate(c *types.Container, policy RetentionPolicy) {
+	y, m, d := time.Unix(0, c.Destroy).UTC().Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	keys := make([]aggKey, 0, len(policy.AggregateLabels)+1)
+	keys = append(keys, aggKey{day: day.UnixNano(), currency: c.Currency})
+	for _, k := range policy.AggregateLabels {
+		if v, ok := c.Labels[k]; ok {
+			keys = append(keys, aggKey{day: day.UnixNano(), label: k, value: v, currency: c.Currency})
+		}
+	}
+
+	for _, key := range keys {
+		agg, ok := store.aggs[key]
+		if !ok && key.label != "" {
+			vkey := aggKey{day: key.day, label: key.label, currency: key.currency}
+			if policy.MaxLabelValues > 0 && store.aggValues[vkey] >= policy.MaxLabelValues {
+				key.value = OtherValue
+				agg, ok = store.aggs[key]
+			}
+		}
+		if !ok {
+			agg = &DailyAggregate{Day: day, Label: key.label, Value: key.value, Currency: key.currency}
+			store.setAggregate(key, agg)
+		}
+		agg.add(c)
+	}
+}
+
+// setAggregate sets the aggregate by key counting the new label values
+func (store *InmemContainers) setAggregate(key aggKey, agg *DailyAggregate) {
+	if _, ok := store.aggs[key]; !ok && key.label != "" && key.value != OtherValue {
+		store.aggValues[aggKey{day: key.day, label: key.label, currency: key.currency}]++
+	}
+	store.aggs[key] = agg
+}
+
+// Aggregates satisfies the Compactor interface.  Aggregates of the same day
+// are sorted by label and value
+func (store *InmemContainers) Aggregates(f func(DailyAggregate) error) error {
+	store.mu.RLock()
+	list := make([]DailyAggregate, 0, len(store.aggs))
+	for _, agg := range store.aggs {
+		list = append(list, *agg)
+	}
+	store.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if !a.Day.Equal(b.Day) {
+			return a.Day.Before(b.Day)
+		}
+		if a.Label != b.Label {
+			return a.Label < b.Label
+		}
+		return a.Value < b.Value
+	})
+
+	for _, agg := range list {
+		if err := f(agg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAggregate satisfies the Compactor interface
+func (store *InmemContainers) SetAggregate(agg DailyAggregate) error {
+	agg.Day = agg.Day.UTC()
+	key := aggKey{day: agg.Day.UnixNano(), label: agg.Label, value: agg.Value, currency: agg.Currency}
+
+	store.mu.Lock()
+	store.setAggregate(key, &agg)
+	store.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/euforia/metermaid/tsdb"
+)
+
+// Series implements a time series storage interface. Series are keyed by
+// their name
+type Series interface {
+	// Append adds data points to the named series creating it if needed
+	Append(name string, dps ...tsdb.DataPoint) error
+	Get(name string) (tsdb.Series, error)
+	// Iter calls f for each series whose name starts with prefix
+	Iter(prefix string, f func(tsdb.Series) error) error
+	// Delete removes the series whose name starts with prefix returning
+	// the number removed
+	Delete(prefix string) (int, error)
+}
+
+// SeriesName returns the name of the series for the given container and
+// metric.  An empty metric returns the prefix of all series of the
+// container
+func SeriesName(containerID, metric string) string {
+	return containerID + "/" + metric
+}
+
+// InmemSeries implements an in memory Series interface
+type InmemSeries struct {
+	mu sync.RWMutex
+	m  map[string]*tsdb.Series
+}
+
+// NewInmemSeries returns a new instance of InmemSeries
+func NewInmemSeries() *InmemSeries {
+	return &InmemSeries{
+		m: make(map[string]*tsdb.Series),
+	}
+}
+
+// Append satisfies the Series interface
+func (store *InmemSeries) Append(name string, dps ...tsdb.DataPoint) error {
+	store.mu.Lock()
+	s, ok := store.m[name]
+	if !ok {
+		s = &tsdb.Series{Name: name}
+		store.m[name] = s
+	}
+	s.Data = s.Data.Insert(dps...)
+	store.mu.Unlock()
+	return nil
+}
+
+// Get satisfies the Series interface.  A copy of the series is returned
+func (store *InmemSeries) Get(name string) (tsdb.Series, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if s, ok := store.m[name]; ok {
+		return tsdb.Series{Name: s.Name, Meta: s.Meta, Data: s.Data.Clone()}, nil
+	}
+	return tsdb.Series{}, ErrNotFound
+}
+
+// Iter satisfies the Series interface
+func (store *InmemSeries) Iter(prefix string, f func(tsdb.Series) error) (err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for name, s := range store.m {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if err = f(tsdb.Series{Name: s.Name, Meta: s.Meta, Data: s.Data.Clone()}); err != nil {
+			break
+		}
+	}
+	return err
+}
+
+// Delete satisfies the Series interface
+func (store *InmemSeries) Delete(prefix string) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var n int
+	for name := range store.m {
+		if strings.HasPrefix(name, prefix) {
+			delete(store.m, name)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/euforia/metermaid/tsdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InmemSeries_Delete(t *testing.T) {
+	store := NewInmemSeries()
+	for _, name := range []string{
+		SeriesName("c1", "net.tx.bytes"),
+		SeriesName("c1", "units"),
+		SeriesName("c10", "units"),
+	} {
+		store.Append(name, tsdb.DataPoint{Timestamp: 1, Value: 1})
+	}
+
+	n, err := store.Delete(SeriesName("c1", ""))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	_, err = store.Get(SeriesName("c1", "units"))
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.Get(SeriesName("c10", "units"))
+	assert.Nil(t, err)
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/types"
+)
+
+// EventType is the kind of change to a container
+type EventType string
+
+// Container change events.  A single change has one event, the first of
+// created, destroyed, updated and cost in that order
+const (
+	EventCreated   EventType = "created"
+	EventDestroyed EventType = "destroyed"
+	EventUpdated   EventType = "updated"
+	// Only the cost and usage estimates changed
+	EventCost EventType = "cost"
+	// The container changed and no longer matches the watch
+	EventRemoved EventType = "removed"
+	// Sent by streams before closing a watch that overflowed
+	EventOverflow EventType = "overflow"
+)
+
+// watchBuffer is the number of events buffered per watch
+const watchBuffer = 256
+
+// Event is a change to a container
+type Event struct {
+	Type      EventType
+	Container types.Container
+}
+
+// Watch delivers the change events of containers matching an expression
+// before or after the change.  A container that starts matching is sent
+// with its change and one that stops matching is sent as removed.  If the
+// consumer falls behind by more than the buffer the watch is closed
+// and Overflowed returns true so the consumer can resync
+type Watch struct {
+	expr fl.Expr
+	ch   chan Event
+
+	mu         sync.Mutex
+	closed     bool
+	overflowed bool
+	// Called once on close to stop delivery
+	unregister func(*Watch)
+}
+
+func newWatch(expr fl.Expr, unregister func(*Watch)) *Watch {
+	return &Watch{
+		expr:       expr,
+		ch:         make(chan Event, watchBuffer),
+		unregister: unregister,
+	}
+}
+
+// Events returns the channel of events.  It is closed with the watch
+func (w *Watch) Events() <-chan Event {
+	return w.ch
+}
+
+// Overflowed returns true if the watch was closed because the consumer fell
+// behind
+func (w *Watch) Overflowed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.overflowed
+}
+
+// Close stops delivery and closes the events channel
+func (w *Watch) Close() {
+	w.unregister(w)
+	w.close(false)
+}
+
+func (w *Watch) close(overflowed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.overflowed = overflowed
+	close(w.ch)
+}
+
+// send delivers the event of a change from prev without blocking.  It
+// returns false if the watch overflowed and was closed
+func (w *Watch) send(ev Event, prev *types.Container) bool {
+	var (
+		match   = w.expr.Eval(&ev.Container)
+		matched = prev != nil && w.expr.Eval(prev)
+	)
+	switch {
+	case !match && !matched:
+		return true
+	case !match:
+		ev.Type = EventRemoved
+	case !matched && ev.Type == EventCost:
+		// New to the watch so more than the cost changed for it
+		ev.Type = EventUpdated
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return true
+	}
+	select {
+	case w.ch <- ev:
+		w.mu.Unlock()
+		return true
+	default:
+		w.mu.Unlock()
+		w.close(true)
+		return false
+	}
+}
+
+// watchers holds the active watches of a store
+type watchers struct {
+	mu      sync.Mutex
+	watches map[*Watch]struct{}
+}
+
+func (ws *watchers) add(expr fl.Expr) *Watch {
+	w := newWatch(expr, ws.remove)
+	ws.mu.Lock()
+	if ws.watches == nil {
+		ws.watches = make(map[*Watch]struct{})
+	}
+	ws.watches[w] = struct{}{}
+	ws.mu.Unlock()
+	return w
+}
+
+func (ws *watchers) remove(w *Watch) {
+	ws.mu.Lock()
+	delete(ws.watches, w)
+	ws.mu.Unlock()
+}
+
+// publish sends the event of a change from prev to all matching watches
+// dropping those that overflowed
+func (ws *watchers) publish(ev Event, prev *types.Container) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for w := range ws.watches {
+		if !w.send(ev, prev) {
+			delete(ws.watches, w)
+		}
+	}
+}
+
+// changeType returns the type of change from prev to c and false if
+// nothing changed
+func changeType(prev *types.Container, c *types.Container) (EventType, bool) {
+	switch {
+	case prev == nil:
+		return EventCreated, true
+	case !prev.Destroyed() && c.Destroyed():
+		return EventDestroyed, true
+	case reflect.DeepEqual(*prev, *c):
+		return "", false
+	case !reflect.DeepEqual(withoutCost(*prev), withoutCost(*c)):
+		return EventUpdated, true
+	}
+	return EventCost, true
+}
+
+// withoutCost returns the container with the computed cost and usage
+// estimates cleared
+func withoutCost(c types.Container) types.Container {
+	c.UnitsBurned = 0
+	c.Currency = ""
+	c.StorageUnitsBurned = 0
+	c.NetworkUnitsBurned = 0
+	c.EnergyKWh = 0
+	c.CarbonCO2e = 0
+	c.CostSources = nil
+	c.CostConfidence = ""
+	return c
+}
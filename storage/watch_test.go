@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/euforia/metermaid/fl"
+	"github.com/euforia/metermaid/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InmemContainers_Watch(t *testing.T) {
+	store := NewInmemContainers()
+	expr, _ := fl.ParseExpr("service=api")
+	watch := store.Watch(expr)
+
+	c := types.Container{ID: "a", Labels: map[string]string{"service": "api"}}
+	store.Set(c)
+	store.Set(c)
+	store.Set(types.Container{ID: "b", Labels: map[string]string{"service": "web"}})
+	c.UnitsBurned = 1
+	store.Set(c)
+	c.Stop = 10
+	store.Set(c)
+	c.Destroy = 20
+	store.Set(c)
+
+	var got []EventType
+	for i := 0; i < 4; i++ {
+		got = append(got, (<-watch.Events()).Type)
+	}
+	assert.Equal(t, []EventType{EventCreated, EventCost, EventUpdated, EventDestroyed}, got)
+
+	// Containers entering and leaving the match
+	b := types.Container{ID: "b", Labels: map[string]string{"service": "api"}}
+	store.Set(b)
+	b.Labels = map[string]string{"service": "web"}
+	store.Set(b)
+	ev := <-watch.Events()
+	assert.Equal(t, EventUpdated, ev.Type)
+	ev = <-watch.Events()
+	assert.Equal(t, EventRemoved, ev.Type)
+	assert.Equal(t, "web", ev.Container.Labels["service"])
+	assert.Equal(t, 0, len(watch.Events()))
+
+	watch.Close()
+	_, ok := <-watch.Events()
+	assert.False(t, ok)
+	assert.False(t, watch.Overflowed())
+	assert.Equal(t, 0, len(store.watchers.watches))
+}
+
+func Test_Watch_Overflow(t *testing.T) {
+	store := NewInmemContainers()
+	watch := store.Watch(fl.TrueExpr{})
+	for i := 0; i <= watchBuffer; i++ {
+		store.Set(types.Container{ID: string(rune('a' + i%26)), CPUShares: int64(i)})
+	}
+
+	var n int
+	for range watch.Events() {
+		n++
+	}
+	assert.Equal(t, watchBuffer, n)
+	assert.True(t, watch.Overflowed())
+	assert.Equal(t, 0, len(store.watchers.watches))
+	watch.Close()
+}
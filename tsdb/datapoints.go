@@ -41,6 +41,21 @@ func (c DataPoints) Sum() (total float64) {
 	return
 }
 
+// Increase returns the total increase of a cumulative counter starting at
+// zero. A drop in value is treated as a counter reset
+func (c DataPoints) Increase() (total float64) {
+	var prev float64
+	for _, p := range c {
+		if p.Value >= prev {
+			total += p.Value - prev
+		} else {
+			total += p.Value
+		}
+		prev = p.Value
+	}
+	return
+}
+
 // Max returns the max of the DataPoints
 func (c DataPoints) Max() (max float64) {
 	for _, p := range c {
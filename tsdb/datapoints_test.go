@@ -227,6 +227,18 @@ func Test_Datapoints_Scale_Sum(t *testing.T) {
 	assert.Equal(t, 2.5, dps.Sum())
 }
 
+func Test_Datapoints_Increase(t *testing.T) {
+	dps := DataPoints{
+		DataPoint{10, 100},
+		DataPoint{20, 150},
+		// Counter reset
+		DataPoint{30, 20},
+		DataPoint{40, 50},
+	}
+	assert.Equal(t, 200.0, dps.Increase())
+	assert.Equal(t, 0.0, DataPoints{}.Increase())
+}
+
 // func Test_Datapoints_Per(t *testing.T) {
 // 	for _, tc := range dpsEncTests {
 // 		assert.Equal(t, tc.enc, tc.dps.Encompasses(tc.s, tc.e))
@@ -1,11 +1,41 @@
 package types
 
 import (
+	"strings"
 	"time"
 
 	"github.com/euforia/metermaid/fl"
 )
 
+// LabelPrefix addresses a container label explicitly in queries e.g.
+// Labels.Team=infra.  Labels starting with a lower case letter may also be
+// queried by their bare name
+const LabelPrefix = "Labels."
+
+// ContainerSchema is the queryable fields of a Container
+var ContainerSchema = &fl.Schema{
+	Fields: map[string]fl.FieldType{
+		"ID":            fl.FieldString,
+		"Name":          fl.FieldString,
+		"Create":        fl.FieldTime,
+		"Start":         fl.FieldTime,
+		"Stop":          fl.FieldTime,
+		"Destroy":       fl.FieldTime,
+		"RunTime":       fl.FieldDuration,
+		"AllocatedTime": fl.FieldDuration,
+		"CPUShares":     fl.FieldInt,
+		"Memory":        fl.FieldInt,
+		"UnitsBurned":   fl.FieldFloat,
+	},
+	DynamicPrefix: LabelPrefix,
+	Dynamic:       fl.DynamicLower,
+	Key:           "ID",
+}
+
+func init() {
+	fl.RegisterSchema("container", ContainerSchema)
+}
+
 // Container holds information about a container that is or was running
 type Container struct {
 	ID        string
@@ -15,13 +45,56 @@ type Container struct {
 	Stop      int64 // epoch nano
 	Destroy   int64 // epoch nano
 	Memory    int64 // bytes
-	CPUShares int64 // MHz?
+	CPUShares int64 // MHz reserved
+	// Raw cpu reservation from the runtime.  When set CPUShares is computed
+	// from it relative to the node capacity
+	CPU *CPUReservation `json:",omitempty"`
+	// Part of the reservation CPUShares was computed from
+	CPUSource string `json:",omitempty"`
 	Labels    map[string]string
 	Tags      map[string]string
+	// Host paths mounted into the container
+	Mounts []Mount `json:",omitempty"`
 	// Units used.  This can be dollars or any other
 	// virtual unit. This represents the total cost between
 	// create and destroy
 	UnitsBurned float64
+	// Currency or unit of UnitsBurned
+	Currency string `json:",omitempty"`
+	// Portion of UnitsBurned from attached storage volumes
+	StorageUnitsBurned float64 `json:",omitempty"`
+	// Portion of UnitsBurned from network egress
+	NetworkUnitsBurned float64 `json:",omitempty"`
+	// Estimated energy used between create and destroy
+	EnergyKWh float64 `json:",omitempty"`
+	// Estimated grams of CO2 equivalent emitted between create and destroy
+	CarbonCO2e float64 `json:",omitempty"`
+	// Price providers used to compute UnitsBurned
+	CostSources []string `json:",omitempty"`
+	// Lowest confidence of the prices used to compute UnitsBurned
+	CostConfidence string `json:",omitempty"`
+}
+
+// CPUReservation is the cpu reservation of a container as configured in
+// the runtime.  Zero values are unset
+type CPUReservation struct {
+	// Hard limit in billionths of a cpu e.g. docker --cpus
+	NanoCPUs int64 `json:",omitempty"`
+	// CFS quota and period in microseconds
+	Quota  int64 `json:",omitempty"`
+	Period int64 `json:",omitempty"`
+	// Cpus the container is pinned to e.g. 0-3,6
+	Cpuset string `json:",omitempty"`
+	// Relative weight against other containers
+	Shares int64 `json:",omitempty"`
+}
+
+// Mount is a host path mounted into a container
+type Mount struct {
+	// Path on the host
+	Source string
+	// Path in the container
+	Destination string
 }
 
 // Destroyed returns true if the container has been destroyed
@@ -29,18 +102,28 @@ func (cont *Container) Destroyed() bool {
 	return cont.Destroy > 0
 }
 
-// RunTime returns duration for which the container was actually running
+// RunTime returns duration for which the container was actually running.
+// A container still running is measured to now
 func (cont *Container) RunTime() time.Duration {
 	return delta(cont.Stop, cont.Start)
 }
 
 // AllocatedTime returns the amount of time container resources were allocated
-// i.e from the time it was created to the time it was completely destroyed
+// i.e from the time it was created to the time it was completely destroyed.
+// A container not yet destroyed is measured to now
 func (cont *Container) AllocatedTime() time.Duration {
 	return delta(cont.Destroy, cont.Create)
 }
 
+// delta returns the time between start and end or now if end is not set.
+// It is zero if start is not set
 func delta(end, start int64) time.Duration {
+	if start == 0 {
+		return 0
+	}
+	if end == 0 {
+		end = time.Now().UnixNano()
+	}
 	if d := end - start; d > -1 {
 		return time.Duration(d)
 	}
@@ -61,6 +144,14 @@ func (cont *Container) Match(query fl.Query) bool {
 // treated as AND's
 func (cont *Container) MatchField(name string, filters ...fl.Filter) bool {
 	switch name {
+	case "ID":
+		for _, filter := range filters {
+			if fl.MatchString(cont.ID, filter) {
+				continue
+			}
+			return false
+		}
+		return true
 	case "Name":
 		for _, filter := range filters {
 			if fl.MatchString(cont.Name, filter) {
@@ -102,6 +193,22 @@ func (cont *Container) MatchField(name string, filters ...fl.Filter) bool {
 			return false
 		}
 		return true
+	case "RunTime":
+		for _, filter := range filters {
+			if fl.MatchDuration(cont.RunTime(), filter) {
+				continue
+			}
+			return false
+		}
+		return true
+	case "AllocatedTime":
+		for _, filter := range filters {
+			if fl.MatchDuration(cont.AllocatedTime(), filter) {
+				continue
+			}
+			return false
+		}
+		return true
 	case "CPUShares":
 		for _, filter := range filters {
 			if fl.MatchInt64(cont.CPUShares, filter) {
@@ -118,8 +225,17 @@ func (cont *Container) MatchField(name string, filters ...fl.Filter) bool {
 			return false
 		}
 		return true
+	case "UnitsBurned":
+		for _, filter := range filters {
+			if fl.MatchFloat64(cont.UnitsBurned, filter) {
+				continue
+			}
+			return false
+		}
+		return true
 
 	default:
+		name = strings.TrimPrefix(name, LabelPrefix)
 		for _, filter := range filters {
 			if cont.MatchLabel(name, filter) {
 				continue
@@ -130,8 +246,44 @@ func (cont *Container) MatchField(name string, filters ...fl.Filter) bool {
 	}
 }
 
+// FieldValue satisfies the fl.Valuer interface.  Unknown fields are labels
+func (cont *Container) FieldValue(name string) interface{} {
+	switch name {
+	case "ID":
+		return cont.ID
+	case "Name":
+		return cont.Name
+	case "Create":
+		return cont.Create
+	case "Start":
+		return cont.Start
+	case "Stop":
+		return cont.Stop
+	case "Destroy":
+		return cont.Destroy
+	case "RunTime":
+		return int64(cont.RunTime())
+	case "AllocatedTime":
+		return int64(cont.AllocatedTime())
+	case "CPUShares":
+		return cont.CPUShares
+	case "Memory":
+		return cont.Memory
+	case "UnitsBurned":
+		return cont.UnitsBurned
+	}
+	if val, ok := cont.Labels[strings.TrimPrefix(name, LabelPrefix)]; ok {
+		return val
+	}
+	return nil
+}
+
+// MatchLabel returns true if the named label matches the filter
 func (cont *Container) MatchLabel(name string, filter fl.Filter) bool {
 	val, ok := cont.Labels[name]
+	if match, isExists := fl.MatchExists(ok, filter); isExists {
+		return match
+	}
 	if !ok {
 		return false
 	}
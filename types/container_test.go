@@ -32,6 +32,13 @@ func Test_Container(t *testing.T) {
 	assert.True(t, testC.MatchField("Name", fl.Filter{Operator: fl.NoOp, Values: []string{"bar"}}))
 }
 
+func Test_Container_MatchLabelExists(t *testing.T) {
+	assert.True(t, testC.MatchField("service", fl.Filter{Operator: fl.OpExists}))
+	assert.False(t, testC.MatchField("team", fl.Filter{Operator: fl.OpExists}))
+	assert.True(t, testC.MatchField("team", fl.Filter{Operator: fl.OpMissing}))
+	assert.True(t, testC.MatchField("service", fl.Filter{Operator: fl.OpPrefix, Values: []string{"my-"}}))
+}
+
 func Test_MatchTime(t *testing.T) {
 	assert.True(t, testC.MatchField("Create", fl.Filter{
 		Operator: fl.OpLessEqual,
@@ -55,3 +62,43 @@ func Test_MatchTime(t *testing.T) {
 	}))
 
 }
+
+func Test_MatchRelative(t *testing.T) {
+	c := &Container{
+		Create:  time.Now().Add(-2 * time.Hour).UnixNano(),
+		Start:   time.Now().Add(-2 * time.Hour).UnixNano(),
+		Stop:    time.Now().Add(-time.Hour).UnixNano(),
+		Destroy: time.Now().UnixNano(),
+	}
+	assert.True(t, c.MatchField("Create", fl.Filter{Operator: fl.OpGreater, Values: []string{"now-3h"}}))
+	assert.False(t, c.MatchField("Create", fl.Filter{Operator: fl.OpGreater, Values: []string{"now-1h"}}))
+	assert.True(t, c.MatchField("RunTime", fl.Filter{Operator: fl.OpGreaterEqual, Values: []string{"59m"}}))
+	assert.True(t, c.MatchField("AllocatedTime", fl.Filter{Operator: fl.OpLess, Values: []string{"3h"}}))
+	assert.False(t, c.MatchField("AllocatedTime", fl.Filter{Operator: fl.OpLess, Values: []string{"5m"}}))
+
+	// Running containers are measured to now
+	c.Stop, c.Destroy = 0, 0
+	assert.True(t, c.MatchField("RunTime", fl.Filter{Operator: fl.OpGreater, Values: []string{"1h"}}))
+	assert.False(t, c.MatchField("AllocatedTime", fl.Filter{Operator: fl.OpLess, Values: []string{"5m"}}))
+	assert.True(t, c.FieldValue("AllocatedTime").(int64) >= int64(2*time.Hour))
+
+	// Containers never started have not run
+	c.Start = 0
+	assert.Equal(t, time.Duration(0), c.RunTime())
+}
+
+func Test_ContainerSchema(t *testing.T) {
+	assert.Equal(t, ContainerSchema, fl.SchemaFor("container"))
+
+	q, err := fl.ParseQuery(map[string][]string{
+		"Labels.service": []string{"my-service"},
+		"Memory":         []string{"ge:100"},
+	}, ContainerSchema)
+	assert.Nil(t, err)
+	assert.True(t, testC.Match(q))
+
+	_, err = fl.ParseQuery(map[string][]string{"Names": []string{"bar"}}, ContainerSchema)
+	assert.NotNil(t, err)
+	_, err = fl.ParseQuery(map[string][]string{"CPUshares": []string{"gt:1"}}, ContainerSchema)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,18 @@
+package types
+
+// ContainerStats holds a single resource usage sample for a container.
+// Counters are cumulative since the container started.
+type ContainerStats struct {
+	ID        string
+	Timestamp int64 // epoch nano
+	// Network bytes received and sent across all interfaces
+	NetRxBytes uint64
+	NetTxBytes uint64
+	// Block device bytes and operations across all devices
+	BlkReadBytes  uint64
+	BlkWriteBytes uint64
+	BlkReadOps    uint64
+	BlkWriteOps   uint64
+	// Size of the container writable layer in bytes. This is a gauge
+	WritableLayerBytes int64
+}
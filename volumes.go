@@ -0,0 +1,193 @@
+package metermaid
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/euforia/metermaid/node"
+	"github.com/euforia/metermaid/pricing"
+	"github.com/euforia/metermaid/types"
+)
+
+// priceVolumes returns the hourly price of each node volume by id
+func priceVolumes(nd *node.Node, sp pricing.StorageProvider, logger *zap.Logger) map[string]float64 {
+	rates := make(map[string]float64, len(nd.Volumes))
+	if sp == nil {
+		return rates
+	}
+
+	for _, vol := range nd.Volumes {
+		rate, err := sp.VolumeHourly(vol, nd.Meta)
+		if err != nil {
+			logger.Info("failed to price volume", zap.String("id", vol.ID), zap.Error(err))
+			continue
+		}
+		rates[vol.ID] = rate
+	}
+	return rates
+}
+
+// allocEnd returns the time container resources were released or now if
+// still allocated
+func allocEnd(c types.Container) int64 {
+	if c.Destroy > 0 {
+		return c.Destroy
+	} else if c.Stop > 0 {
+		return c.Stop
+	}
+	return time.Now().UnixNano()
+}
+
+// containerVolumes returns the priced node volumes mounted by the container
+func (mm *meterMaid) containerVolumes(c types.Container) map[string]struct{} {
+	vols := make(map[string]struct{})
+	for _, m := range c.Mounts {
+		if vol, ok := mm.Node().VolumeFor(m.Source); ok {
+			if _, priced := mm.volumeRates[vol.ID]; priced {
+				vols[vol.ID] = struct{}{}
+			}
+		}
+	}
+	return vols
+}
+
+// mountSpan returns when the container mounted its volumes and when it
+// released them.  The end is zero while they are still allocated
+func mountSpan(c types.Container) [2]int64 {
+	if c.Destroy == 0 && c.Stop == 0 {
+		return [2]int64{c.Create, 0}
+	}
+	return [2]int64{c.Create, allocEnd(c)}
+}
+
+// indexMounts records the volumes mounted by the container and forgets
+// destroyed containers that can no longer overlap a live one
+func (mm *meterMaid) indexMounts(c types.Container) {
+	for id := range mm.containerVolumes(c) {
+		spans, ok := mm.mounts[id]
+		if !ok {
+			spans = make(map[string][2]int64)
+			mm.mounts[id] = spans
+		}
+		spans[c.ID] = mountSpan(c)
+
+		// Containers mounting the volume later are created after now
+		oldest := time.Now().UnixNano()
+		for _, span := range spans {
+			if span[1] == 0 && span[0] < oldest {
+				oldest = span[0]
+			}
+		}
+		for cid, span := range spans {
+			if cid != c.ID && span[1] > 0 && span[1] <= oldest {
+				delete(spans, cid)
+			}
+		}
+	}
+}
+
+// computeStoragePrice computes the price of the volumes mounted by the
+// container.  The price of a volume over time is split evenly between the
+// containers mounting it at that time.
+func (mm *meterMaid) computeStoragePrice(c types.Container) float64 {
+	mm.indexMounts(c)
+
+	var (
+		start = c.Create
+		end   = allocEnd(c)
+		total float64
+	)
+	for id := range mm.containerVolumes(c) {
+		shares, _ := volumeShares(mm.volumeRates[id], mm.mounts[id], start, end)
+		total += shares[c.ID]
+	}
+	return total
+}
+
+// storagePrice returns the total price of all priced volumes on the node
+// between start and end, and the portion of that not mounted by any
+// container which is attributed to the node itself.
+func (mm *meterMaid) storagePrice(start, end time.Time) (total, unallocated float64) {
+	if len(mm.volumeRates) == 0 {
+		return
+	}
+
+	var (
+		s     = start.UnixNano()
+		e     = end.UnixNano()
+		spans = make(map[string]map[string][2]int64, len(mm.volumeRates))
+	)
+	mm.cstore.Iter(func(c types.Container) error {
+		for id := range mm.containerVolumes(c) {
+			if spans[id] == nil {
+				spans[id] = make(map[string][2]int64)
+			}
+			spans[id][c.ID] = mountSpan(c)
+		}
+		return nil
+	})
+
+	hours := time.Duration(e - s).Hours()
+	for id, rate := range mm.volumeRates {
+		_, free := volumeShares(rate, spans[id], s, e)
+		total += rate * hours
+		unallocated += free
+	}
+	return
+}
+
+// volumeShares splits the price of a volume between start and end by the
+// containers mounting it.  Each interval between mounts and unmounts is
+// split evenly between the containers mounted throughout it.  Intervals
+// without any are unallocated.  The shares and the unallocated price sum to
+// the price of the volume.  Spans without an end are open until end
+func volumeShares(rate float64, spans map[string][2]int64, start, end int64) (map[string]float64, float64) {
+	clip := func(span [2]int64) (int64, int64) {
+		s, e := span[0], span[1]
+		if e == 0 || e > end {
+			e = end
+		}
+		if s < start {
+			s = start
+		}
+		return s, e
+	}
+
+	bounds := []int64{start, end}
+	for _, span := range spans {
+		if s, e := clip(span); s < e {
+			bounds = append(bounds, s, e)
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	var (
+		shares      = make(map[string]float64, len(spans))
+		unallocated float64
+		mounted     = make([]string, 0, len(spans))
+	)
+	for i := 1; i < len(bounds); i++ {
+		from, to := bounds[i-1], bounds[i]
+		if from == to {
+			continue
+		}
+		price := rate * time.Duration(to-from).Hours()
+
+		mounted = mounted[:0]
+		for id, span := range spans {
+			if s, e := clip(span); s <= from && e >= to {
+				mounted = append(mounted, id)
+			}
+		}
+		if len(mounted) == 0 {
+			unallocated += price
+			continue
+		}
+		for _, id := range mounted {
+			shares[id] += price / float64(len(mounted))
+		}
+	}
+	return shares, unallocated
+}
@@ -0,0 +1,40 @@
+package metermaid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_volumeShares(t *testing.T) {
+	h := int64(time.Hour)
+	spans := map[string][2]int64{
+		"a": {0, 10 * h},
+		"b": {5 * h, 11 * h},
+		// Still mounted
+		"c": {12 * h, 0},
+		// Outside of the range
+		"d": {30 * h, 40 * h},
+	}
+
+	shares, unallocated := volumeShares(1, spans, 0, 20*h)
+	assert.InDelta(t, 5+2.5, shares["a"], 1e-9)
+	assert.InDelta(t, 2.5+1, shares["b"], 1e-9)
+	assert.InDelta(t, 8, shares["c"], 1e-9)
+	assert.Equal(t, float64(0), shares["d"])
+	assert.InDelta(t, 1, unallocated, 1e-9)
+
+	// The parts sum to the price of the volume
+	sum := unallocated
+	for _, share := range shares {
+		sum += share
+	}
+	assert.InDelta(t, 20, sum, 1e-9)
+
+	// Clipped to the range
+	shares, unallocated = volumeShares(1, spans, 8*h, 12*h)
+	assert.InDelta(t, 1, shares["a"], 1e-9)
+	assert.InDelta(t, 1+1, shares["b"], 1e-9)
+	assert.InDelta(t, 1, unallocated, 1e-9)
+}